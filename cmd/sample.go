@@ -9,20 +9,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	stdlog "log"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/stdr"
-	"github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/file"
-	"github.com/knadh/koanf/v2"
 	"github.com/spf13/afero"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/notification"
+	"github.com/SAP/k8s-resource-validator/pkg/postprocessing"
 	"github.com/SAP/k8s-resource-validator/pkg/validation"
 	"github.com/SAP/k8s-resource-validator/pkg/validators/allowed_pods"
 	"github.com/SAP/k8s-resource-validator/pkg/validators/freshness"
@@ -40,28 +38,58 @@ func main() {
 	appFs := afero.NewOsFs()
 	ctx = context.WithValue(ctx, common.FileSystemContextKey, appFs)
 
-	// create validation instance
+	// read the configuration layers from command-line flags, e.g. --config-map center/validator-config --set freshness.thresholdInHours=48
+	// (environment variables with the prefix KRV_CONFIG_ set values too)
+	loader := validation.DefaultConfigLoader()
+	loader.RegisterFlags(flag.CommandLine)
+	allowlistByNamespace := flag.Bool("allowlist-by-namespace", false, "write a generated allowlist to one file per namespace (see the allowlist command)")
+	flag.Parse()
+
+	// create validation instance, and load its configuration
+	// (set CONFIG_STRICT=true or --strict-config to fail on unknown keys and invalid values, instead of logging them)
 	validationInstance, err := validation.NewValidation(ctx)
 	if err != nil {
 		panic(err)
 	}
+	loadedConfig, err := validationInstance.LoadConfig(loader)
+	if err != nil {
+		panic(err)
+	}
+	config := loadedConfig.Config
+
+	// optionally, print the effective configuration and the origin of each value
+	// e.g. `k8s-resource-validator --set abort.configMapName=deploying config`
+	if flag.Arg(0) == "config" {
+		if err = loadedConfig.Print(os.Stdout); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// optionally, generate an allowlist that allows the pods that are currently running, instead of validating them
+	// e.g. `k8s-resource-validator allowlist /config/allowlist.yaml`, or `k8s-resource-validator --allowlist-by-namespace allowlist /config/allowlist.d`
+	if flag.NArg() > 1 && flag.Arg(0) == "allowlist" {
+		err = validationInstance.Collect()
+		if err == nil {
+			items := allowed_pods.GenerateAllowlist(validationInstance.GetResourceIndex())
+			if *allowlistByNamespace {
+				err = allowed_pods.WriteAllowlistByNamespace(appFs, flag.Arg(1), items)
+			} else {
+				err = allowed_pods.WriteAllowlist(appFs, flag.Arg(1), items)
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
 
-	// load configuration
 	configDirectory := "."
 	configDirectoryOverride := os.Getenv("CONFIG_DIR")
 	if configDirectoryOverride != "" {
 		configDirectory = configDirectoryOverride
 	}
 
-	k := koanf.New(".")
-
-	k.Load(file.Provider(filepath.Join(configDirectory, "config.yaml")), yaml.Parser())
-
-	var freshnessThresholdInHours int32 = 24 * 28 // 4 weeks
-	if k.Int("freshness.thresholdInHours") != 0 {
-		freshnessThresholdInHours = int32(k.Int("freshness.thresholdInHours"))
-	}
-
 	// instantiate relevant validators
 	var validatorList []common.Validator
 	readinessValidator, err := readiness.NewReadinessValidator(ctx, configDirectory, false)
@@ -69,7 +97,7 @@ func main() {
 		validatorList = append(validatorList, readinessValidator)
 	}
 
-	freshnessValidator, err := freshness.NewFreshnessValidator(ctx, freshnessThresholdInHours)
+	freshnessValidator, err := freshness.NewFreshnessValidator(ctx, config.Freshness.ThresholdInHours)
 	if err == nil {
 		validatorList = append(validatorList, freshnessValidator)
 	}
@@ -84,53 +112,67 @@ func main() {
 		validatorList = append(validatorList, privilegedPodsValidator)
 	}
 
-	// optionally, set an abort function
-	// validationInstance.SetAbortFunc(func() bool {
-	// 	return len(validationInstance.Resources) == 0 // place your custom abort logic here
-	// })
+	// optionally, post-process violations (unless configured in config.yaml):
+	// ignore privileged pods if it's the only violation by a specific resource,
+	// and merge the violations of a resource into a single violation of the highest severity, if it caused more than one violation
+	if config.PostProcessors == nil {
+		validationInstance.SetPostProcessors(
+			postprocessing.NewFilter(postprocessing.Selector{Validators: []string{privileged_pods.ValidatorName}}, true),
+			postprocessing.NewEscalation(postprocessing.Selector{}, 2, common.SeverityCritical),
+			postprocessing.NewMerge(),
+		)
+	}
+
+	// optionally, replace the abort conditions of config.yaml with custom ones
+	// validationInstance.SetAbortConditions(validation.AbortConditionFunc(func(ctx context.Context, state validation.AbortState) (bool, string, error) {
+	// 	return len(state.Resources) == 0, "no resources were found", nil // place your custom abort logic here
+	// }))
 
-	// perform the validation
-	violations, _ := validationInstance.Validate(validatorList)
+	// optionally, generate a baseline of the current violations instead of reporting them
+	// e.g. `k8s-resource-validator baseline /config/baseline.yaml`
+	generateBaseline := flag.NArg() > 1 && flag.Arg(0) == "baseline"
+	if generateBaseline {
+		validationInstance.SetBaseline(nil)
+	}
 
-	// optionally, process (non-violation) errors
+	// perform the validation, unless an abort condition is met (see SetAbortConditions):
+	// an aborted run has no violations, and mustn't be mistaken for a run that found none.
+	// The result also lists the exempted violations, and the number of violations suppressed by the baseline
+	result := validationInstance.ValidateWithResult(validatorList, nil)
 
-	// aggregate violations
-	aggregatedViolations := getCustomViolations(violations)
+	err = validation.LogOutcome(ctx, result)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if result.Outcome == validation.OutcomeAborted {
+		return
+	}
+
+	if generateBaseline {
+		err = validation.WriteBaseline(appFs, flag.Arg(1), validation.NewBaselineFromResult(result, nil))
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// optionally, process (non-violation) errors
 
 	// log the violations
-	err = validation.LogViolations(ctx, aggregatedViolations, 0)
+	err = validation.LogResultViolations(ctx, result, common.SeverityCritical)
 	if err != nil {
 		fmt.Println(err)
 	}
-}
 
-// perform custom post-validation manipulation, before sending violations to logger
-func getCustomViolations(originalViolations []common.Violation) []common.Violation {
-	customViolations := []common.Violation{}
-	violationsGroupedByResource := common.GetViolationsGroupedByResource(originalViolations)
-	for _, violationsPerResource := range violationsGroupedByResource {
-		if len(violationsPerResource) == 1 {
-			// ignore privileged pods if it's the only violation by a specific resource
-			if violationsPerResource[0].ValidatorName != "built-in:privileged-pods" {
-				customViolations = append(customViolations, violationsPerResource[0])
-			}
-		} else if len(violationsPerResource) > 1 {
-			// elevate the violation level, if a specific resource caused more than one violation
-			aggregatedViolation := common.Violation{}
-			var sb strings.Builder
-			for i, aViolation := range violationsPerResource {
-				if i > 0 {
-					sb.WriteString("+")
-				}
-				sb.WriteString(aViolation.ValidatorName)
-			}
-			aggregatedViolation.Resource = violationsPerResource[0].Resource
-			aggregatedViolation.Level = 0 // set to highest severity
-			aggregatedViolation.ValidatorName = sb.String()
-			aggregatedViolation.Message = "aggregated violation"
-			customViolations = append(customViolations, aggregatedViolation)
+	// optionally, notify webhooks of new and resolved violations
+	if config.Notifications != nil {
+		var notifier *notification.Notifier
+		notifier, err = notification.NewNotifier(ctx, *config.Notifications)
+		if err == nil {
+			err = notifier.Notify(result)
+		}
+		if err != nil {
+			fmt.Println(err)
 		}
 	}
-
-	return customViolations
 }
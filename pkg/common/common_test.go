@@ -2,7 +2,10 @@ package common
 
 import (
 	"context"
+	"io/fs"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/testr"
@@ -11,8 +14,11 @@ import (
 	"github.com/spf13/afero"
 
 	// "github.com/SAP/k8s-resource-validator/pkg/validators/fake"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
 var (
@@ -44,8 +50,9 @@ var _ = Describe("Utils", func() {
 		})
 
 		It("resource is exempt", func() {
-			ExemptPodLabelName = "label1"
-			ExemptPodLabelValue = "exempt"
+			config := NewConfig()
+			config.Exempt.LabelName = "label1"
+			config.Exempt.LabelValue = "exempt"
 			var labels map[string]string = make(map[string]string)
 			labels["label1"] = "exempt"
 
@@ -62,7 +69,15 @@ var _ = Describe("Utils", func() {
 
 			resource.SetLabels(labels)
 
+			Expect(config.IsExempt(resource)).To(BeTrue())
+
+			DeferCleanup(func(name string, value string) {
+				ExemptPodLabelName, ExemptPodLabelValue = name, value
+			}, ExemptPodLabelName, ExemptPodLabelValue)
+			ExemptPodLabelName = "label1"
+			ExemptPodLabelValue = "exempt"
 			Expect(IsExempt(resource)).To(BeTrue())
+			Expect(NewConfig().Exempt.LabelName).To(Equal("label1"))
 		})
 
 		It("resource is not exempt", func() {
@@ -77,6 +92,7 @@ var _ = Describe("Utils", func() {
 				},
 			}
 
+			Expect(NewConfig().IsExempt(resource)).To(BeFalse())
 			Expect(IsExempt(resource)).To(BeFalse())
 		})
 
@@ -103,6 +119,30 @@ var _ = Describe("Utils", func() {
 			Expect(violation.Resource.GetName()).To(Equal(violation.Resource.GetName()))
 		})
 
+		It("violation with structured fields", func() {
+			resource := unstructured.Unstructured{}
+			resource.SetKind(KIND_POD)
+			violation := NewViolation(resource, "message", 1, "validator").
+				WithRuleID("rule").
+				WithFieldPath("spec.containers[0].image").
+				WithContainerName("container").
+				WithRemediation("remediation").
+				WithDocumentationURL("https://example.com").
+				WithDetail("key", "value")
+
+			Expect(violation.RuleID).To(Equal("rule"))
+			Expect(violation.FieldPath).To(Equal("spec.containers[0].image"))
+			Expect(violation.ContainerName).To(Equal("container"))
+			Expect(violation.Remediation).To(Equal("remediation"))
+			Expect(violation.DocumentationURL).To(Equal("https://example.com"))
+			Expect(violation.Details).To(Equal(map[string]string{"key": "value"}))
+
+			// details are not shared between derived violations
+			derived := violation.WithDetail("other", "value")
+			Expect(derived.Details).To(HaveLen(2))
+			Expect(violation.Details).To(HaveLen(1))
+		})
+
 		It("get pods", func() {
 			podResource := unstructured.Unstructured{
 				Object: map[string]interface{}{
@@ -206,5 +246,308 @@ var _ = Describe("Utils", func() {
 			groupedViolations := GetViolationsGroupedByResource(violations)
 			Expect(len(groupedViolations)).To(Equal(2))
 		})
+
+		It("violation fingerprint", func() {
+			resource := unstructured.Unstructured{}
+			resource.SetKind(KIND_POD)
+			resource.SetName("pod1")
+			resource.SetNamespace("namespace")
+
+			violation := NewViolation(resource, "message", 1, "a")
+			Expect(violation.Fingerprint()).To(Equal(NewViolation(resource, "message", 0, "a").Fingerprint()))
+			Expect(violation.Fingerprint()).NotTo(Equal(NewViolation(resource, "other message", 1, "a").Fingerprint()))
+			Expect(violation.Fingerprint()).NotTo(Equal(NewViolation(resource, "message", 1, "b").Fingerprint()))
+
+			otherResource := resource.DeepCopy()
+			otherResource.SetNamespace("other")
+			Expect(violation.Fingerprint()).NotTo(Equal(NewViolation(*otherResource, "message", 1, "a").Fingerprint()))
+		})
+
+		It("violation fingerprint ignores volatile message parts", func() {
+			resource := unstructured.Unstructured{}
+			resource.SetKind(KIND_POD)
+			resource.SetName("pod1")
+
+			violation := NewViolation(resource, "Pod is stale: created 2023-01-02T03:04:05Z, age 700h5m0s", 1, "a")
+			sameViolation := NewViolation(resource, "  pod is STALE: created 2023-02-01T00:00:00Z,  age 1400h0m0s\n", 1, "a")
+			Expect(violation.Fingerprint()).To(Equal(sameViolation.Fingerprint()))
+
+			otherRule := violation
+			otherRule.RuleID = "rule"
+			Expect(violation.Fingerprint()).NotTo(Equal(otherRule.Fingerprint()))
+		})
+
+		DescribeTable("normalize durations, but not values that look like them",
+			func(message string, expected string) {
+				Expect(NormalizeMessage(message)).To(Equal(expected))
+			},
+			Entry("hours, minutes and seconds", "age 700h5m0s", "age <duration>"),
+			Entry("minutes and seconds", "waited 5m30s", "waited <duration>"),
+			Entry("fractions of seconds", "took 1.5s", "took <duration>"),
+			Entry("sub-second units", "took 500ms and 20µs", "took <duration> and <duration>"),
+			Entry("quantity", "cpu limit 500m exceeds 200m", "cpu limit 500m exceeds 200m"),
+			Entry("name", "container app-2s is privileged", "container app-2s is privileged"),
+			Entry("count", "3h of 4h", "3h of 4h"),
+		)
+
+		It("diff violations", func() {
+			newPodViolation := func(name string) Violation {
+				resource := unstructured.Unstructured{}
+				resource.SetKind(KIND_POD)
+				resource.SetName(name)
+				return NewViolation(resource, "message", 1, "a")
+			}
+
+			previous := []Violation{newPodViolation("a"), newPodViolation("b")}
+			current := []Violation{newPodViolation("b"), newPodViolation("c")}
+
+			diff := DiffViolations(previous, current)
+			Expect(diff.Added).To(HaveLen(1))
+			Expect(diff.Added[0].Resource.GetName()).To(Equal("c"))
+			Expect(diff.Resolved).To(HaveLen(1))
+			Expect(diff.Resolved[0].Resource.GetName()).To(Equal("a"))
+			Expect(diff.Unchanged).To(HaveLen(1))
+			Expect(diff.Unchanged[0].Resource.GetName()).To(Equal("b"))
+		})
+
+		It("severities", func() {
+			severity, err := ParseSeverity("Critical")
+			Expect(err).To(Succeed())
+			Expect(severity).To(Equal(SeverityCritical))
+			Expect(severity.Level()).To(Equal(0))
+
+			_, err = ParseSeverity("urgent")
+			Expect(err).To(HaveOccurred())
+
+			Expect(SeverityFromLevel(1)).To(Equal(SeverityHigh))
+			Expect(SeverityFromLevel(-1)).To(Equal(SeverityCritical))
+			Expect(SeverityFromLevel(7)).To(Equal(SeverityInfo))
+
+			resource := unstructured.Unstructured{}
+			violation := NewViolation(resource, "message", 2, "a")
+			Expect(violation.Severity()).To(Equal(SeverityMedium))
+			Expect(violation.WithSeverity(SeverityLow).Level).To(Equal(3))
+		})
+
+		It("filter violations by namespace", func() {
+			resource1 := unstructured.Unstructured{}
+			resource1.SetKind(KIND_POD)
+			resource1.SetName("pod1")
+			resource1.SetNamespace("namespace1")
+
+			resource2 := unstructured.Unstructured{}
+			resource2.SetKind(KIND_POD)
+			resource2.SetName("pod2")
+			resource2.SetNamespace("namespace2")
+
+			violations := []Violation{
+				NewViolation(resource1, "message", 1, "a"),
+				NewViolation(resource2, "message", 1, "a"),
+			}
+
+			Expect(FilterViolationsByNamespace(violations, nil)).To(HaveLen(2))
+
+			filtered := FilterViolationsByNamespace(violations, []string{"namespace2"})
+			Expect(filtered).To(HaveLen(1))
+			Expect(filtered[0].Resource.GetName()).To(Equal("pod2"))
+		})
+	})
+
+	Describe("Resource index", func() {
+		newResource := func(apiVersion string, kind string, name string, uid string, owners ...*unstructured.Unstructured) unstructured.Unstructured {
+			resource := unstructured.Unstructured{}
+			resource.SetAPIVersion(apiVersion)
+			resource.SetKind(kind)
+			resource.SetName(name)
+			resource.SetNamespace("namespace")
+			resource.SetUID(types.UID(uid))
+			var references []metav1.OwnerReference
+			for _, owner := range owners {
+				references = append(references, metav1.OwnerReference{
+					APIVersion: owner.GetAPIVersion(),
+					Kind:       owner.GetKind(),
+					Name:       owner.GetName(),
+					UID:        owner.GetUID(),
+				})
+			}
+			resource.SetOwnerReferences(references)
+			return resource
+		}
+
+		It("looks up resources and their ownership relations", func() {
+			deployment := newResource("apps/v1", KIND_DEPLOYMENT, "app", "1")
+			replicaSet := newResource("apps/v1", KIND_REPLICA_SET, "app-1", "2", &deployment)
+			pod1 := newResource("v1", KIND_POD, "app-1-a", "3", &replicaSet)
+			pod2 := newResource("v1", KIND_POD, "app-1-b", "4", &replicaSet)
+			index := NewResourceIndex([]unstructured.Unstructured{deployment, replicaSet, pod1, pod2})
+
+			Expect(index.ByGroupVersionKind(pod1.GroupVersionKind())).To(HaveLen(2))
+			found, ok := index.Get(ResourceKey{Group: "apps", Kind: KIND_REPLICA_SET, Namespace: "namespace", Name: "app-1"})
+			Expect(ok).To(BeTrue())
+			Expect(found.GetUID()).To(Equal(types.UID("2")))
+			_, ok = index.Get(ResourceKey{Kind: KIND_REPLICA_SET, Namespace: "namespace", Name: "app-1"}) // wrong group
+			Expect(ok).To(BeFalse())
+			found, ok = index.GetByUID("3")
+			Expect(ok).To(BeTrue())
+			Expect(found.GetName()).To(Equal("app-1-a"))
+
+			Expect(index.Owners(&pod1)).To(HaveLen(1))
+			Expect(index.Owners(&pod1)[0].GetName()).To(Equal("app-1"))
+			Expect(index.Dependents(&replicaSet)).To(HaveLen(2))
+			ancestors := index.Ancestors(&pod2)
+			Expect(ancestors).To(HaveLen(2))
+			Expect(ancestors[1].GetKind()).To(Equal(KIND_DEPLOYMENT))
+
+			Expect(index.DanglingOwnerReferences()).To(BeEmpty())
+			Expect(index.OwnershipCycles()).To(BeEmpty())
+		})
+
+		It("resolves owner references by UID", func() {
+			replicaSet := newResource("apps/v1", KIND_REPLICA_SET, "app-1", "new")
+			oldReplicaSet := newResource("apps/v1", KIND_REPLICA_SET, "app-1", "old")
+			pod := newResource("v1", KIND_POD, "app-1-a", "3", &oldReplicaSet)
+			index := NewResourceIndex([]unstructured.Unstructured{replicaSet, pod})
+
+			// the owner was recreated with the same name
+			Expect(index.Owners(&pod)).To(BeEmpty())
+			Expect(index.DanglingOwnerReferences()).To(HaveLen(1))
+			Expect(index.DanglingOwnerReferences()[0].Dependent.GetName()).To(Equal("app-1-a"))
+		})
+
+		It("owners of kinds that were not indexed are not dangling", func() {
+			owner := newResource("example.com/v1", "Custom", "custom", "1")
+			pod := newResource("v1", KIND_POD, "pod", "2", &owner)
+			index := NewResourceIndex([]unstructured.Unstructured{pod})
+
+			Expect(index.Owners(&pod)).To(BeEmpty())
+			Expect(index.DanglingOwnerReferences()).To(BeEmpty())
+		})
+
+		It("detects ownership cycles", func() {
+			first := newResource("apps/v1", KIND_REPLICA_SET, "first", "1")
+			second := newResource("apps/v1", KIND_REPLICA_SET, "second", "2", &first)
+			first = newResource("apps/v1", KIND_REPLICA_SET, "first", "1", &second)
+			pod := newResource("v1", KIND_POD, "pod", "3", &first)
+			index := NewResourceIndex([]unstructured.Unstructured{pod, first, second})
+
+			Expect(index.OwnershipCycles()).To(HaveLen(1))
+			Expect(index.OwnershipCycles()[0]).To(HaveLen(2))
+			Expect(index.Ancestors(&pod)).To(HaveLen(2))
+		})
+	})
+
+	Describe("Configuration", func() {
+		It("parses over the defaults", func() {
+			content := `abort:
+  configMapName: "abort"
+severities:
+  - validator: built-in:freshness
+    severity: info
+notifications:
+  timeout: 5s
+validators:
+  custom:
+    maxReplicas: 3
+`
+			config, warnings, err := ParseConfig([]byte(content), true)
+			Expect(err).To(Succeed())
+			Expect(warnings).To(BeEmpty())
+			Expect(config.Abort.ConfigMapName).To(Equal("abort"))
+			Expect(config.Abort.ConfigMapNamespace).To(Equal(NewConfig().Abort.ConfigMapNamespace))
+			Expect(config.Freshness).To(Equal(NewConfig().Freshness))
+			Expect(config.Severities).To(Equal([]SeverityOverride{{Validator: "built-in:freshness", Severity: SeverityInfo}}))
+			Expect(*config.Notifications.Timeout).To(Equal(5 * time.Second))
+			Expect(config.Notifications.MaxRetries).To(BeNil())
+			Expect(config.PostProcessors).To(BeNil())
+
+			var custom struct {
+				MaxReplicas int `yaml:"maxReplicas"`
+			}
+			found, err := config.Section("custom", &custom)
+			Expect(err).To(Succeed())
+			Expect(found).To(BeTrue())
+			Expect(custom.MaxReplicas).To(Equal(3))
+		})
+
+		It("strict mode fails with the locations of invalid values", func() {
+			content := "abort:\n  configMapName: \"abort\"\n  configMapNme: \"typo\"\nfreshness:\n  thresholdInHours: soon\n"
+
+			_, _, err := ParseConfig([]byte(content), true)
+			Expect(err).To(MatchError(ContainSubstring("line 3: field configMapNme not found")))
+			Expect(err).To(MatchError(ContainSubstring("line 5: cannot unmarshal")))
+
+			config, warnings, err := ParseConfig([]byte(content), false)
+			Expect(err).To(Succeed())
+			Expect(warnings).To(HaveLen(2))
+			Expect(config.Abort.ConfigMapName).To(Equal("abort"))
+			Expect(config.Values).To(HaveKey("freshness"))
+		})
+
+		It("syntax errors are always errors", func() {
+			_, _, err := ParseConfig([]byte("abort: [\n"), false)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("the published JSON schema is up to date", func() {
+			// regenerate with: UPDATE_SCHEMA=true go test ./pkg/common/
+			const schemaFile = "../../docs/config.schema.json"
+			schema, err := ConfigJSONSchema()
+			Expect(err).To(Succeed())
+			schema = append(schema, '\n')
+			if os.Getenv("UPDATE_SCHEMA") == "true" {
+				Expect(os.WriteFile(schemaFile, schema, 0644)).To(Succeed())
+			}
+
+			published, err := os.ReadFile(schemaFile)
+			Expect(err).To(Succeed())
+			Expect(string(published)).To(Equal(string(schema)), "regenerate with: UPDATE_SCHEMA=true go test ./pkg/common/")
+		})
+
+		DescribeTable("resolve keys regardless of case",
+			func(key string, expected string, expectedFound bool) {
+				resolved, found := ResolveConfigKey(key)
+				Expect(found).To(Equal(expectedFound))
+				Expect(resolved).To(Equal(expected))
+			},
+			Entry("leaf", "ABORT.CONFIGMAPNAME", "abort.configMapName", true),
+			Entry("section", "freshness", "freshness", true),
+			Entry("pointer", "notifications.maxretries", "notifications.maxRetries", true),
+			Entry("free-form section", "Validators.MyValidator.limit", "validators.MyValidator.limit", true),
+			Entry("unknown key", "abort.configMapNme", "", false),
+			Entry("below a leaf", "freshness.thresholdInHours.x", "", false),
+		)
+
+		It("read the documents of lists from files and ConfigMaps", func() {
+			appFs = afero.NewMemMapFs()
+			Expect(afero.WriteFile(appFs, "/config/allowlist.yaml", []byte("[]"), 0644)).To(Succeed())
+			Expect(afero.WriteFile(appFs, "/config/allowlist.d/b.yaml", []byte("[]"), 0644)).To(Succeed())
+			Expect(afero.WriteFile(appFs, "/config/allowlist.d/a.yml", []byte("[]"), 0644)).To(Succeed())
+			Expect(afero.WriteFile(appFs, "/config/allowlist.d/README.md", []byte(""), 0644)).To(Succeed())
+
+			paths, err := ListFiles(appFs, configDirectory, "allowlist.yaml")
+			Expect(err).To(Succeed())
+			Expect(paths).To(Equal([]string{"/config/allowlist.yaml", "/config/allowlist.d/a.yml", "/config/allowlist.d/b.yaml"}))
+			_, err = ReadListFiles(appFs, configDirectory, "readinesslist.yaml")
+			Expect(err).To(MatchError(fs.ErrNotExist))
+
+			configMap := func(namespace string, labels map[string]string, data map[string]string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "allowlist", Labels: labels}, Data: data}
+			}
+			selected := map[string]string{"allowlist": "true"}
+			clientSet := k8sfake.NewSimpleClientset(
+				configMap("team-b", selected, map[string]string{"allowlist.yaml": "[]"}),
+				configMap("platform", selected, map[string]string{"allowlist.yaml": "[]"}),
+				configMap("team-a", nil, map[string]string{"allowlist.yaml": "[]"}),
+				configMap("team-c", selected, map[string]string{"other.yaml": "[]"}),
+			)
+			documents, err := ReadListConfigMaps(context.Background(), clientSet, ListConfigMapsConfig{Selector: "allowlist=true", TrustedNamespaces: []string{"platform"}}, "allowlist.yaml")
+			Expect(err).To(Succeed())
+			Expect(documents).To(HaveLen(2))
+			Expect(documents[0].Origin).To(Equal("configmap platform/allowlist (allowlist.yaml)"))
+			Expect(documents[0].Owns("team-b")).To(BeTrue())
+			Expect(documents[1].Owner).To(Equal("team-b"))
+			Expect(documents[1].Owns("team-a")).To(BeFalse())
+			Expect(documents[1].ConfigMap.GetKind()).To(Equal(KIND_CONFIG_MAP))
+		})
 	})
 })
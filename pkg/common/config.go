@@ -0,0 +1,371 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+/*
+Config is the configuration of a Validation, as read from config.yaml (see docs/config.schema.json for its JSON Schema).
+Each Validation owns its Config, so that differently configured validations can run in the same process.
+It is provided to validators that implement ConfigAware.
+*/
+type Config struct {
+	Abort          AbortConfig           `yaml:"abort"`
+	Rollouts       RolloutsConfig        `yaml:"rollouts"`
+	Exempt         ExemptionConfig       `yaml:"exempt"`
+	Collection     CollectionConfig      `yaml:"collection"`
+	Freshness      FreshnessConfig       `yaml:"freshness"`
+	AllowedPods    AllowedPodsConfig     `yaml:"allowedPods"`
+	Readiness      ReadinessConfig       `yaml:"readiness"`
+	Severities     []SeverityOverride    `yaml:"severities"`
+	PostProcessors []PostProcessorConfig `yaml:"postProcessors"` // nil if not configured
+	Notifications  *NotificationConfig   `yaml:"notifications"`  // nil if not configured
+	/*
+		Validators holds the settings of custom validators, by a key of the validator's choice.
+		See Section().
+	*/
+	Validators map[string]any `yaml:"validators"`
+	/*
+		Values are all values of config.yaml (including the ones above).
+		See Section().
+	*/
+	Values map[string]any `yaml:"-"`
+}
+
+/*
+AbortConfig configures the conditions that abort validations, e.g. during deployments:
+
+	abort:
+	  combine: any
+	  conditions:
+	    - configMap: {namespace: center, name: landscape-state, field: deploying}
+	    - rollout: {}
+	    - maintenanceWindow: {days: [sat, sun], start: "22:00", end: "04:00", timeZone: Europe/Berlin}
+
+If no conditions are configured, validations are aborted if the ConfigMap field ConfigMapField is set to "true".
+*/
+type AbortConfig struct {
+	ConfigMapNamespace string                 `yaml:"configMapNamespace"`
+	ConfigMapName      string                 `yaml:"configMapName"`
+	ConfigMapField     string                 `yaml:"configMapField"`
+	Combine            string                 `yaml:"combine"` // "any" (default) aborts if any of the conditions is met, "all" if all of them are
+	Conditions         []AbortConditionConfig `yaml:"conditions"`
+}
+
+/*
+AbortConditionConfig configures a single abort condition: exactly one of its fields is set
+*/
+type AbortConditionConfig struct {
+	ConfigMap           *ConfigMapFieldConfig      `yaml:"configMap"`
+	NamespaceAnnotation *NamespaceAnnotationConfig `yaml:"namespaceAnnotation"`
+	Lease               *LeaseConfig               `yaml:"lease"`
+	Rollout             *RolloutConfig             `yaml:"rollout"`
+	MaintenanceWindow   *MaintenanceWindowConfig   `yaml:"maintenanceWindow"`
+}
+
+/*
+ConfigMapFieldConfig is met if the field of the ConfigMap has the value Value ("true" by default)
+*/
+type ConfigMapFieldConfig struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Field     string `yaml:"field"`
+	Value     string `yaml:"value"`
+}
+
+/*
+NamespaceAnnotationConfig is met if the namespace has the annotation, with the value Value (if it isn't empty)
+*/
+type NamespaceAnnotationConfig struct {
+	Namespace  string `yaml:"namespace"`
+	Annotation string `yaml:"annotation"`
+	Value      string `yaml:"value"`
+}
+
+/*
+LeaseConfig is met if the Lease exists, unless it expired (i.e. it wasn't renewed within its duration)
+*/
+type LeaseConfig struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+}
+
+/*
+RolloutConfig is met if any of the collected workloads of the given kinds is rolling out
+*/
+type RolloutConfig struct {
+	Kinds []string `yaml:"kinds"` // defaults to Deployment, StatefulSet, DaemonSet and ReplicaSet (like RolloutsConfig)
+}
+
+/*
+MaintenanceWindowConfig is met during a recurring time window, e.g. from 22:00 to 04:00 (the next day) on weekends
+*/
+type MaintenanceWindowConfig struct {
+	Days     []string `yaml:"days"`     // on which the window starts: mon, tue, wed, thu, fri, sat or sun; defaults to every day
+	Start    string   `yaml:"start"`    // e.g. 22:00
+	End      string   `yaml:"end"`      // e.g. 04:00; if it's before Start, the window ends on the next day
+	TimeZone string   `yaml:"timeZone"` // e.g. Europe/Berlin; defaults to UTC
+}
+
+/*
+RolloutsConfig configures how runs handle workloads that are rolling out, since their resources are in transition:
+
+	rollouts:
+	  mode: wait
+	  timeout: 10m
+*/
+type RolloutsConfig struct {
+	Mode         string        `yaml:"mode"`         // wait or scopeOut (see RolloutConfig for aborting runs); if empty, rollouts are not detected
+	Kinds        []string      `yaml:"kinds"`        // defaults to Deployment, StatefulSet, DaemonSet and ReplicaSet
+	Timeout      time.Duration `yaml:"timeout"`      // wait: after which the run is aborted; defaults to 10m
+	PollInterval time.Duration `yaml:"pollInterval"` // wait: at which the resources are fetched again; defaults to 15s
+}
+
+/*
+ExemptionConfig exempts resources from validation by their labels, or by the labels of their namespaces.
+Selectors use the label selector syntax of kubectl, including set-based requirements, e.g. "app in (agent, proxy), !monitored".
+
+It is read from the exempt key of config.yaml:
+
+	exempt:
+	  selectors: ["resources.gardener.cloud/managed-by=gardener"]
+	  namespaceSelectors: ["team in (infra, platform)"]
+	  validators:
+	    - validator: built-in:freshness
+	      selectors: ["batch"]
+*/
+type ExemptionConfig struct {
+	/*
+		LabelName and LabelValue exempt resources that have a single label value.
+		Deprecated: use Selectors
+	*/
+	LabelName  string `yaml:"labelName"`
+	LabelValue string `yaml:"labelValue"`
+	// Selectors exempt matching resources from all validators
+	Selectors []string `yaml:"selectors"`
+	// NamespaceSelectors exempt the resources of matching namespaces from all validators
+	NamespaceSelectors []string `yaml:"namespaceSelectors"`
+	// Validators lists exemptions from specific validators
+	Validators []ValidatorExemption `yaml:"validators"`
+}
+
+/*
+ValidatorExemption exempts resources from a single validator
+*/
+type ValidatorExemption struct {
+	Validator          string   `yaml:"validator"`
+	Selectors          []string `yaml:"selectors"`
+	NamespaceSelectors []string `yaml:"namespaceSelectors"`
+}
+
+/*
+CollectionConfig configures which resources are fetched from the cluster
+*/
+type CollectionConfig struct {
+	// AdditionalResourceTypes are fetched in addition to the built-in kinds and to the types of additionalResourceTypes.yaml
+	AdditionalResourceTypes []ResourceType `yaml:"additionalResourceTypes"`
+}
+
+/*
+ResourceType identifies a resource type by its API group, version and (plural) resource name, e.g. apps, v1, deployments
+*/
+type ResourceType struct {
+	Group    string `yaml:"group"`
+	Version  string `yaml:"version"`
+	Resource string `yaml:"resource"`
+}
+
+/*
+FreshnessConfig configures the built-in freshness validator
+*/
+type FreshnessConfig struct {
+	ThresholdInHours int32 `yaml:"thresholdInHours"` // resource age, above which it is stale
+}
+
+/*
+AllowedPodsConfig configures the built-in allowed pods validator
+*/
+type AllowedPodsConfig struct {
+	ReportStaleEntries bool                 `yaml:"reportStaleEntries"` // report the allowlist entries that matched no resource of a run, so that they can be pruned
+	ConfigMaps         ListConfigMapsConfig `yaml:"configMaps"`         // extend the allowlist by the entries of ConfigMaps
+}
+
+/*
+ReadinessConfig configures the built-in readiness validator
+*/
+type ReadinessConfig struct {
+	ConfigMaps ListConfigMapsConfig `yaml:"configMaps"` // extend the readinesslist by the entries of ConfigMaps
+}
+
+/*
+SeverityOverride sets the severity of the violations of a validator, or of one of its rules.
+An override of a rule takes precedence over an override of the whole validator.
+*/
+type SeverityOverride struct {
+	Validator string   `yaml:"validator"`
+	Rule      string   `yaml:"rule"` // optional: if empty, the override applies to all violations of the validator
+	Severity  Severity `yaml:"severity"`
+}
+
+/*
+PostProcessorConfig configures a built-in post-processor, e.g. in the "postProcessors" list of config.yaml:
+
+	postProcessors:
+	  - type: filter
+	    match:
+	      validators: ["built-in:privileged-pods"]
+	    onlyIfSole: true
+	  - type: escalate
+	    minViolationsPerResource: 2
+	    severity: critical
+	  - type: rollup
+	  - type: merge
+	  - type: limit
+	    max: 100
+*/
+type PostProcessorConfig struct {
+	Type  string            `yaml:"type"` // one of filter, escalate, merge, limit, rollup
+	Match ViolationSelector `yaml:"match"`
+
+	OnlyIfSole               bool     `yaml:"onlyIfSole"`               // filter
+	MinViolationsPerResource int      `yaml:"minViolationsPerResource"` // escalate
+	Severity                 Severity `yaml:"severity"`                 // escalate
+	Max                      int      `yaml:"max"`                      // limit
+}
+
+/*
+NotificationConfig configures the webhooks that are notified of new and resolved violations
+*/
+type NotificationConfig struct {
+	Webhooks     []WebhookConfig `yaml:"webhooks"`
+	Timeout      *time.Duration  `yaml:"timeout"`      // per request; defaults to 10s (if nil), 0 disables it
+	MaxRetries   *int            `yaml:"maxRetries"`   // defaults to 3 (if nil), 0 disables retries
+	RetryBackoff *time.Duration  `yaml:"retryBackoff"` // doubled after each retry; defaults to 1s (if nil)
+	/*
+		StateFile persists the fingerprints of the previous result, so that changes are detected across process restarts
+		(e.g. when running as a CronJob). If empty, state is kept in memory only.
+	*/
+	StateFile string `yaml:"stateFile"`
+	/*
+		NotifyInitialViolations causes all violations of the first result to be notified as new.
+		By default, the first result only establishes the state to compare against.
+	*/
+	NotifyInitialViolations bool `yaml:"notifyInitialViolations"`
+}
+
+type WebhookConfig struct {
+	URL      string            `yaml:"url"`
+	Template string            `yaml:"template"` // a text/template rendering the JSON payload; see notification.TemplateData. Defaults to notification.DefaultTemplate
+	Headers  map[string]string `yaml:"headers"`
+}
+
+/*
+ConfigAware is implemented by validators that use the configuration of the Validation that runs them.
+SetConfig is called before each run.
+*/
+type ConfigAware interface {
+	SetConfig(config Config)
+}
+
+/*
+NewConfig returns the default configuration
+*/
+func NewConfig() Config {
+	return Config{
+		Abort: AbortConfig{
+			ConfigMapNamespace: "center",
+			ConfigMapName:      "landscape-state",
+			ConfigMapField:     "deploying",
+		},
+		Exempt: ExemptionConfig{
+			LabelName:  ExemptPodLabelName,
+			LabelValue: ExemptPodLabelValue,
+		},
+		Freshness: FreshnessConfig{
+			ThresholdInHours: 24 * 28, // 4 weeks
+		},
+	}
+}
+
+/*
+Section decodes the settings of a custom validator into target, using its yaml tags.
+The settings are looked up by key in the validators section of config.yaml, and otherwise among its top-level keys
+(which are only allowed if strict mode is off).
+It returns false if the key doesn't exist.
+*/
+func (c Config) Section(key string, target any) (bool, error) {
+	value, found := c.Validators[key]
+	if !found {
+		value, found = c.Values[key]
+	}
+	if !found {
+		return false, nil
+	}
+
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return true, err
+	}
+	if err = yaml.Unmarshal(content, target); err != nil {
+		return true, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return true, nil
+}
+
+/*
+ParseConfig reads the content of a config.yaml file over the default configuration (see NewConfig()).
+
+In strict mode, unknown keys and values of the wrong type are errors.
+Otherwise, they are returned as warnings, and the remaining values are read.
+Both errors and warnings state the line of the offending value, e.g. "line 3: field foo not found in type common.AbortConfig".
+Syntax errors are always errors.
+*/
+func ParseConfig(content []byte, strict bool) (Config, []string, error) {
+	config := NewConfig()
+	if len(bytes.TrimSpace(content)) == 0 {
+		return config, nil, nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&config)
+
+	// type errors don't stop decoding, so the remaining values are read
+	var typeError *yaml.TypeError
+	if err != nil && !errors.As(err, &typeError) {
+		return NewConfig(), nil, err
+	}
+	if err = yaml.Unmarshal(content, &config.Values); err != nil {
+		return NewConfig(), nil, err
+	}
+
+	if typeError == nil {
+		return config, nil, nil
+	}
+	if strict {
+		errs := make([]error, 0, len(typeError.Errors))
+		for _, message := range typeError.Errors {
+			errs = append(errs, errors.New(message))
+		}
+		return NewConfig(), nil, errors.Join(errs...)
+	}
+	return config, typeError.Errors, nil
+}
+
+/*
+IsExempt returns true if the resource has the label Exempt.LabelName with the value Exempt.LabelValue.
+The built-in pod validators skip such pods; all other exemptions are applied by the validation engine to the violations of all validators.
+*/
+func (c Config) IsExempt(resource unstructured.Unstructured) bool {
+	if c.Exempt.LabelName == "" {
+		return false
+	}
+	return labels.SelectorFromSet(labels.Set{c.Exempt.LabelName: c.Exempt.LabelValue}).Matches(labels.Set(resource.GetLabels()))
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var (
+	// volatile parts of messages, which should not affect a violation's identity
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[t ]\d{2}:\d{2}:\d{2}(\.\d+)?(z|[+-]\d{2}:?\d{2})?`)
+	// durations as formatted by time.Duration, if they can't be mistaken for other values (such as the quantity 500m or a name like app-2s):
+	// those with minutes and seconds (e.g. 5m0s or 1h2m3s), fractions of seconds (e.g. 1.5s), or sub-second units (e.g. 500ms)
+	durationPattern   = regexp.MustCompile(`\b((\d+h)?\d+m\d+(\.\d+)?s|\d+\.\d+s|\d+(\.\d+)?(ms|us|µs|ns))\b`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+/*
+ViolationDiff is the result of comparing the violations of two validation runs
+*/
+type ViolationDiff struct {
+	Added     []Violation // violations that are only in the current run
+	Resolved  []Violation // violations that are only in the previous run
+	Unchanged []Violation // violations that are in both runs (as reported by the current run)
+}
+
+/*
+Fingerprint identifies a violation across validation runs.
+It is derived from the validator, the rule, the resource's group, kind, namespace and name, and the normalized message (see NormalizeMessage).
+*/
+func (v Violation) Fingerprint() string {
+	return ComputeFingerprint(v.ValidatorName, v.RuleID, NewViolationTarget(v.Resource), v.Message)
+}
+
+func ComputeFingerprint(validatorName string, ruleID string, target ViolationTarget, message string) string {
+	hash := sha256.New()
+	for _, part := range []string{validatorName, ruleID, target.Group, target.Kind, target.Namespace, target.Name, NormalizeMessage(message)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0}) // separator, so that e.g. "ab"+"c" and "a"+"bc" differ
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+/*
+NormalizeMessage removes differences between messages that don't change their meaning:
+case, surrounding and repeated whitespace, as well as timestamps and durations (e.g. a resource's age)
+*/
+func NormalizeMessage(message string) string {
+	message = strings.ToLower(message)
+	message = timestampPattern.ReplaceAllString(message, "<timestamp>")
+	message = durationPattern.ReplaceAllString(message, "<duration>")
+	message = whitespacePattern.ReplaceAllString(message, " ")
+	return strings.TrimSpace(message)
+}
+
+/*
+DiffViolations compares the violations of two validation runs by their fingerprints.
+The order of the violations within each run is preserved.
+*/
+func DiffViolations(previous []Violation, current []Violation) ViolationDiff {
+	var response ViolationDiff
+	response.Added, response.Resolved, response.Unchanged = DiffByFingerprint(previous, current, Violation.Fingerprint)
+	return response
+}
+
+/*
+DiffByFingerprint compares two sets of items (e.g. violations, or reports of violations) by their fingerprints,
+and returns the items that are only in current, those that are only in previous, and those of current that are in both.
+The order of the items within each set is preserved.
+*/
+func DiffByFingerprint[T any](previous []T, current []T, fingerprint func(T) string) (added []T, resolved []T, unchanged []T) {
+	previousFingerprints := make(map[string]bool, len(previous))
+	for _, item := range previous {
+		previousFingerprints[fingerprint(item)] = true
+	}
+
+	currentFingerprints := make(map[string]bool, len(current))
+	for _, item := range current {
+		itemFingerprint := fingerprint(item)
+		currentFingerprints[itemFingerprint] = true
+		if previousFingerprints[itemFingerprint] {
+			unchanged = append(unchanged, item)
+		} else {
+			added = append(added, item)
+		}
+	}
+
+	for _, item := range previous {
+		if !currentFingerprints[fingerprint(item)] {
+			resolved = append(resolved, item)
+		}
+	}
+
+	return added, resolved, unchanged
+}
@@ -0,0 +1,264 @@
+package common
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/*
+ResourceKey identifies a resource by its API group, kind, namespace and name
+*/
+type ResourceKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func NewResourceKey(resource *unstructured.Unstructured) ResourceKey {
+	return ResourceKey{
+		Group:     resource.GroupVersionKind().Group,
+		Kind:      resource.GetKind(),
+		Namespace: resource.GetNamespace(),
+		Name:      resource.GetName(),
+	}
+}
+
+func (k ResourceKey) String() string {
+	kind := schema.GroupKind{Group: k.Group, Kind: k.Kind}.String()
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s/%s", kind, k.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, k.Namespace, k.Name)
+}
+
+/*
+DanglingOwnerReference is an owner reference to a resource that doesn't exist
+*/
+type DanglingOwnerReference struct {
+	Dependent *unstructured.Unstructured
+	Reference metav1.OwnerReference
+}
+
+/*
+ResourceIndex indexes the resources of a validation run by their GVK, key and UID, as well as by their ownership relations.
+It is built once per run (see Validation), and provided to validators and post-processors that implement ResourceIndexAware.
+
+A ResourceIndex is read-only, and therefore safe for concurrent use.
+*/
+type ResourceIndex struct {
+	resources       []unstructured.Unstructured
+	byGVK           map[schema.GroupVersionKind][]*unstructured.Unstructured
+	byKey           map[ResourceKey]*unstructured.Unstructured
+	byUID           map[types.UID]*unstructured.Unstructured
+	groupKinds      map[schema.GroupKind]bool
+	owners          map[*unstructured.Unstructured][]*unstructured.Unstructured
+	dependents      map[*unstructured.Unstructured][]*unstructured.Unstructured
+	danglingOwners  []DanglingOwnerReference
+	ownershipCycles [][]*unstructured.Unstructured
+}
+
+/*
+ResourceIndexAware is implemented by validators and post-processors that use the run's ResourceIndex.
+SetResourceIndex is called before each run.
+*/
+type ResourceIndexAware interface {
+	SetResourceIndex(index *ResourceIndex)
+}
+
+func NewResourceIndex(resources []unstructured.Unstructured) *ResourceIndex {
+	index := ResourceIndex{
+		resources:  resources,
+		byGVK:      make(map[schema.GroupVersionKind][]*unstructured.Unstructured),
+		byKey:      make(map[ResourceKey]*unstructured.Unstructured, len(resources)),
+		byUID:      make(map[types.UID]*unstructured.Unstructured, len(resources)),
+		groupKinds: make(map[schema.GroupKind]bool),
+		owners:     make(map[*unstructured.Unstructured][]*unstructured.Unstructured),
+		dependents: make(map[*unstructured.Unstructured][]*unstructured.Unstructured),
+	}
+
+	for i := range resources {
+		resource := &resources[i]
+		gvk := resource.GroupVersionKind()
+		index.byGVK[gvk] = append(index.byGVK[gvk], resource)
+		index.byKey[NewResourceKey(resource)] = resource
+		if uid := resource.GetUID(); uid != "" {
+			index.byUID[uid] = resource
+		}
+		index.groupKinds[gvk.GroupKind()] = true
+	}
+
+	for i := range resources {
+		dependent := &resources[i]
+		for _, reference := range dependent.GetOwnerReferences() {
+			owner, found := index.Owner(dependent, reference)
+			if !found {
+				// owners of kinds that were not fetched are unknown, rather than dangling
+				if index.groupKinds[referenceGroupKind(reference)] {
+					index.danglingOwners = append(index.danglingOwners, DanglingOwnerReference{Dependent: dependent, Reference: reference})
+				}
+				continue
+			}
+			index.owners[dependent] = append(index.owners[dependent], owner)
+			index.dependents[owner] = append(index.dependents[owner], dependent)
+		}
+	}
+
+	index.ownershipCycles = index.findOwnershipCycles()
+
+	return &index
+}
+
+/*
+Resources returns all indexed resources
+*/
+func (i *ResourceIndex) Resources() []unstructured.Unstructured {
+	return i.resources
+}
+
+func (i *ResourceIndex) ByGroupVersionKind(gvk schema.GroupVersionKind) []*unstructured.Unstructured {
+	return i.byGVK[gvk]
+}
+
+func (i *ResourceIndex) Get(key ResourceKey) (*unstructured.Unstructured, bool) {
+	resource, found := i.byKey[key]
+	return resource, found
+}
+
+func (i *ResourceIndex) GetByUID(uid types.UID) (*unstructured.Unstructured, bool) {
+	resource, found := i.byUID[uid]
+	return resource, found
+}
+
+/*
+Owner resolves one of the dependent's owner references.
+References are resolved by UID if possible, and otherwise by group, kind and name (in the dependent's namespace, or cluster-scoped).
+*/
+func (i *ResourceIndex) Owner(dependent *unstructured.Unstructured, reference metav1.OwnerReference) (*unstructured.Unstructured, bool) {
+	if reference.UID != "" {
+		if owner, found := i.byUID[reference.UID]; found {
+			return owner, true
+		}
+	}
+
+	groupKind := referenceGroupKind(reference)
+	for _, namespace := range []string{dependent.GetNamespace(), ""} {
+		owner, found := i.byKey[ResourceKey{Group: groupKind.Group, Kind: groupKind.Kind, Namespace: namespace, Name: reference.Name}]
+		if found && (reference.UID == "" || owner.GetUID() == "" || owner.GetUID() == reference.UID) {
+			return owner, true
+		}
+	}
+
+	return nil, false
+}
+
+/*
+Owners returns the resource's direct owners that exist among the indexed resources
+*/
+func (i *ResourceIndex) Owners(resource *unstructured.Unstructured) []*unstructured.Unstructured {
+	return i.owners[i.indexed(resource)]
+}
+
+/*
+Dependents returns the resources that are directly owned by the resource
+*/
+func (i *ResourceIndex) Dependents(resource *unstructured.Unstructured) []*unstructured.Unstructured {
+	return i.dependents[i.indexed(resource)]
+}
+
+/*
+Ancestors returns the resource's owners, their owners etc. (breadth-first, each at most once)
+*/
+func (i *ResourceIndex) Ancestors(resource *unstructured.Unstructured) []*unstructured.Unstructured {
+	var response []*unstructured.Unstructured
+	visited := map[*unstructured.Unstructured]bool{i.indexed(resource): true}
+	queue := i.Owners(resource)
+	for len(queue) > 0 {
+		owner := queue[0]
+		queue = queue[1:]
+		if visited[owner] {
+			continue
+		}
+		visited[owner] = true
+		response = append(response, owner)
+		queue = append(queue, i.owners[owner]...)
+	}
+	return response
+}
+
+/*
+DanglingOwnerReferences returns the owner references to resources that don't exist.
+A reference is only considered dangling if resources of the owner's kind were indexed; otherwise, the owner may just not have been fetched.
+*/
+func (i *ResourceIndex) DanglingOwnerReferences() []DanglingOwnerReference {
+	return i.danglingOwners
+}
+
+/*
+OwnershipCycles returns the cycles of owner references, e.g. A owns B, which owns A
+*/
+func (i *ResourceIndex) OwnershipCycles() [][]*unstructured.Unstructured {
+	return i.ownershipCycles
+}
+
+/*
+indexed returns the indexed resource with the same identity as resource, which may be a copy
+*/
+func (i *ResourceIndex) indexed(resource *unstructured.Unstructured) *unstructured.Unstructured {
+	if indexed, found := i.byKey[NewResourceKey(resource)]; found {
+		return indexed
+	}
+	return resource
+}
+
+func (i *ResourceIndex) findOwnershipCycles() [][]*unstructured.Unstructured {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	var cycles [][]*unstructured.Unstructured
+	state := make(map[*unstructured.Unstructured]int)
+	var path []*unstructured.Unstructured
+
+	var visit func(resource *unstructured.Unstructured)
+	visit = func(resource *unstructured.Unstructured) {
+		state[resource] = inProgress
+		path = append(path, resource)
+		for _, owner := range i.owners[resource] {
+			switch state[owner] {
+			case unvisited:
+				visit(owner)
+			case inProgress:
+				start := slices.Index(path, owner)
+				cycles = append(cycles, slices.Clone(path[start:]))
+			}
+		}
+		path = path[:len(path)-1]
+		state[resource] = done
+	}
+
+	for j := range i.resources {
+		if resource := &i.resources[j]; state[resource] == unvisited {
+			visit(resource)
+		}
+	}
+
+	// report cycles in a deterministic order
+	slices.SortFunc(cycles, func(a, b []*unstructured.Unstructured) int {
+		return cmp.Compare(NewResourceKey(a[0]).String(), NewResourceKey(b[0]).String())
+	})
+	return cycles
+}
+
+func referenceGroupKind(reference metav1.OwnerReference) schema.GroupKind {
+	groupVersion, _ := schema.ParseGroupVersion(reference.APIVersion)
+	return schema.GroupKind{Group: groupVersion.Group, Kind: reference.Kind}
+}
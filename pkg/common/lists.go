@@ -0,0 +1,178 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// the suffix of the directory, whose YAML files extend a list file, e.g. allowlist.d/ of allowlist.yaml
+	listDirectorySuffix = ".d"
+
+	// RuleNamespaceNotOwned is violated by the items of a ListDocument, which list resources of a namespace that the document doesn't own
+	RuleNamespaceNotOwned = "namespace-not-owned"
+)
+
+/*
+ListConfigMapsConfig selects the ConfigMaps that hold documents of a list (e.g. of allowlist.yaml), so that teams can own the entries of their namespaces:
+
+	configMaps:
+	  selector: "k8s-resource-validator/allowlist=true"
+	  trustedNamespaces: [platform]
+
+ConfigMaps are looked up in all namespaces. Unless its namespace is trusted, a ConfigMap may only list resources of its own namespace.
+*/
+type ListConfigMapsConfig struct {
+	Selector          string   `yaml:"selector"`          // label selector (kubectl syntax); ConfigMaps aren't read if empty
+	Key               string   `yaml:"key"`               // of the document in the ConfigMaps' data; defaults to the name of the list file, e.g. allowlist.yaml
+	TrustedNamespaces []string `yaml:"trustedNamespaces"` // whose ConfigMaps may list resources of any namespace
+}
+
+/*
+ListDocument is a document of a list, as read from a file or a ConfigMap
+*/
+type ListDocument struct {
+	Origin    string // the path of the file, or e.g. "configmap team-a/allowlist (allowlist.yaml)"
+	Content   []byte
+	ConfigMap *unstructured.Unstructured // the ConfigMap (without its data), which problems of the document are reported for; nil for files
+	Owner     string                     // the namespace, whose resources the document may list; empty if it may list resources of any namespace
+}
+
+/*
+Owns returns true if the document may list resources of the namespace
+*/
+func (d ListDocument) Owns(namespace string) bool {
+	return d.Owner == "" || d.Owner == namespace
+}
+
+/*
+NewViolation returns a violation of the document's ConfigMap.
+Problems of the ConfigMaps of teams don't fail runs, since they're usually caused by a single team; they're reported as violations of the ConfigMap instead.
+*/
+func (d ListDocument) NewViolation(validatorName string, ruleID string, message string, remediation string) Violation {
+	return NewViolation(*d.ConfigMap, message, SeverityMedium.Level(), validatorName).
+		WithRuleID(ruleID).
+		WithRemediation(remediation)
+}
+
+/*
+ListFiles returns the paths of the files of a list: fileName in dir, if it exists, and the YAML files in the directory named after it
+with a ".d" suffix (e.g. allowlist.d/ of allowlist.yaml), in lexical order.
+*/
+func ListFiles(appFs afero.Fs, dir string, fileName string) ([]string, error) {
+	var response []string
+	path := filepath.Join(dir, fileName)
+	if _, err := appFs.Stat(path); err == nil {
+		response = append(response, path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	directory := filepath.Join(dir, strings.TrimSuffix(fileName, filepath.Ext(fileName))+listDirectorySuffix)
+	entries, err := afero.ReadDir(appFs, directory) // sorted by name
+	if errors.Is(err, fs.ErrNotExist) {
+		return response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		extension := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (extension == ".yaml" || extension == ".yml") {
+			response = append(response, filepath.Join(directory, entry.Name()))
+		}
+	}
+	return response, nil
+}
+
+/*
+ReadListFiles reads the files of a list (see ListFiles).
+It returns an error that wraps fs.ErrNotExist if there are none.
+*/
+func ReadListFiles(appFs afero.Fs, dir string, fileName string) ([]ListDocument, error) {
+	paths, err := ListFiles(appFs, dir, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("couldn't find %s: %w", filepath.Join(dir, fileName), fs.ErrNotExist)
+	}
+
+	response := make([]ListDocument, 0, len(paths))
+	for _, path := range paths {
+		content, err := afero.ReadFile(appFs, path)
+		if err != nil {
+			return nil, err
+		}
+		response = append(response, ListDocument{Origin: path, Content: content})
+	}
+	return response, nil
+}
+
+/*
+ReadListConfigMaps reads the documents of a list from the ConfigMaps selected by config, in the order of their namespaces and names.
+ConfigMaps without the key are skipped.
+*/
+func ReadListConfigMaps(ctx context.Context, clientSet kubernetes.Interface, config ListConfigMapsConfig, fileName string) ([]ListDocument, error) {
+	if config.Selector == "" {
+		return nil, nil
+	}
+	if _, err := labels.Parse(config.Selector); err != nil {
+		return nil, fmt.Errorf("invalid ConfigMap selector %q: %w", config.Selector, err)
+	}
+	if clientSet == nil {
+		return nil, errors.New("ConfigMaps can't be read without a client")
+	}
+
+	key := config.Key
+	if key == "" {
+		key = fileName
+	}
+
+	configMaps, err := clientSet.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: config.Selector})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(configMaps.Items, func(a, b corev1.ConfigMap) int {
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+
+	var response []ListDocument
+	for _, configMap := range configMaps.Items {
+		content, found := configMap.Data[key]
+		if !found {
+			continue
+		}
+
+		resource := &unstructured.Unstructured{}
+		resource.SetAPIVersion("v1")
+		resource.SetKind(KIND_CONFIG_MAP)
+		resource.SetNamespace(configMap.Namespace)
+		resource.SetName(configMap.Name)
+		resource.SetLabels(configMap.Labels)
+
+		document := ListDocument{
+			Origin:    fmt.Sprintf("configmap %s/%s (%s)", configMap.Namespace, configMap.Name, key),
+			Content:   []byte(content),
+			ConfigMap: resource,
+			Owner:     configMap.Namespace,
+		}
+		if slices.Contains(config.TrustedNamespaces, configMap.Namespace) {
+			document.Owner = ""
+		}
+		response = append(response, document)
+	}
+	return response, nil
+}
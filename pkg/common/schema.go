@@ -0,0 +1,119 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+ConfigJSONSchema returns the JSON Schema of config.yaml, as derived from the yaml tags of Config.
+It is published as docs/config.schema.json, e.g. for validation and completion in editors.
+*/
+func ConfigJSONSchema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeFor[Config]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "k8s-resource-validator config.yaml"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Duration]():
+		return map[string]any{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`}
+	case reflect.TypeFor[Severity]():
+		return map[string]any{"type": "string", "enum": severities}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			properties[name] = typeSchema(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+/*
+ResolveConfigKey maps a dot-delimited key of config.yaml, regardless of case, onto its canonical form,
+e.g. "ABORT.CONFIGMAPNAME" onto "abort.configMapName".
+Keys within free-form sections (i.e. validators) are returned as given. It returns false if the key is unknown.
+*/
+func ResolveConfigKey(key string) (string, bool) {
+	t := reflect.TypeFor[Config]()
+	segments := strings.Split(key, ".")
+	for i, segment := range segments {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, found := configField(t, segment)
+			if !found {
+				return "", false
+			}
+			segments[i] = field.name
+			t = field.Type
+		case reflect.Map:
+			if t.Elem().Kind() == reflect.Interface {
+				return strings.Join(segments, "."), true
+			}
+			t = t.Elem()
+		default:
+			return "", false // below a leaf value
+		}
+	}
+	return strings.Join(segments, "."), true
+}
+
+type namedField struct {
+	reflect.StructField
+	name string // as in config.yaml
+}
+
+func configField(t reflect.Type, name string) (namedField, bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tagName, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tagName == "-" || !field.IsExported() {
+			continue
+		}
+		if tagName == "" {
+			tagName = strings.ToLower(field.Name)
+		}
+		if strings.EqualFold(tagName, name) {
+			return namedField{StructField: field, name: tagName}, true
+		}
+	}
+	return namedField{}, false
+}
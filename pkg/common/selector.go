@@ -0,0 +1,30 @@
+package common
+
+import (
+	"slices"
+)
+
+/*
+ViolationSelector selects violations by their properties.
+Empty fields match all violations; a violation is selected if it matches all non-empty fields.
+*/
+type ViolationSelector struct {
+	Validators []string   `yaml:"validators"`
+	Rules      []string   `yaml:"rules"`
+	Kinds      []string   `yaml:"kinds"`
+	Namespaces []string   `yaml:"namespaces"`
+	Severities []Severity `yaml:"severities"`
+}
+
+func (s ViolationSelector) Matches(violation Violation) bool {
+	target := NewViolationTarget(violation.Resource)
+	return matchesAny(s.Validators, violation.ValidatorName) &&
+		matchesAny(s.Rules, violation.RuleID) &&
+		matchesAny(s.Kinds, target.Kind) &&
+		matchesAny(s.Namespaces, target.Namespace) &&
+		matchesAny(s.Severities, violation.Severity())
+}
+
+func matchesAny[E comparable](values []E, value E) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
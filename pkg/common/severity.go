@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Severity is a named violation level
+*/
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical" // level 0
+	SeverityHigh     Severity = "high"     // level 1
+	SeverityMedium   Severity = "medium"   // level 2
+	SeverityLow      Severity = "low"      // level 3
+	SeverityInfo     Severity = "info"     // level 4 and above
+)
+
+// ordered from the most severe; the index is the level
+var severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+/*
+ParseSeverity accepts the name of a severity, regardless of case
+*/
+func ParseSeverity(value string) (Severity, error) {
+	for _, severity := range severities {
+		if strings.EqualFold(value, string(severity)) {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("invalid severity %q: must be one of %v", value, severities)
+}
+
+/*
+SeverityFromLevel maps a violation level onto a severity.
+Levels below 0 are critical, levels above 4 are info.
+*/
+func SeverityFromLevel(level int) Severity {
+	if level < 0 {
+		return SeverityCritical
+	}
+	if level >= len(severities) {
+		return SeverityInfo
+	}
+	return severities[level]
+}
+
+/*
+Level maps a severity onto a violation level. Unknown severities are info.
+*/
+func (s Severity) Level() int {
+	for level, severity := range severities {
+		if s == severity {
+			return level
+		}
+	}
+	return SeverityInfo.Level()
+}
+
+func (v Violation) Severity() Severity {
+	return SeverityFromLevel(v.Level)
+}
+
+func (v Violation) WithSeverity(severity Severity) Violation {
+	v.Level = severity.Level()
+	return v
+}
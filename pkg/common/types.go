@@ -2,6 +2,7 @@ package common
 
 import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
 )
 
 type FileSystemContextKeyType string
@@ -17,11 +18,69 @@ type Validator interface {
 	GetName() string
 }
 
+/*
+PostProcessor transforms the violations of a validation run (e.g. filters, merges or escalates them)
+before they are returned. resources are all resources of the run.
+*/
+type PostProcessor interface {
+	Process(violations []Violation, resources []unstructured.Unstructured) []Violation
+	GetName() string
+}
+
+/*
+Reloadable is implemented by validators (and other components) that read files, which may change while the process runs,
+e.g. mounted ConfigMaps of a long-running deployment. See package reload.
+*/
+type Reloadable interface {
+	// WatchedFiles returns the paths of the files that are read by Reload()
+	WatchedFiles() []string
+	/*
+		Reload reads and validates the files, and replaces the current content atomically.
+		If the files are invalid, it returns an error, and the current content remains in use.
+	*/
+	Reload() error
+}
+
+/*
+Watchable is implemented by Reloadables that read their files on each run, unless they're reloaded by a watcher when their files change.
+SetWatched is called by reload.NewWatcher.
+*/
+type Watchable interface {
+	SetWatched(watched bool)
+}
+
 type Violation struct {
 	Message       string                     // an error describing the violation
 	Resource      *unstructured.Unstructured // the violating resource
 	Level         int                        // verbosity level: 0 is the most severe
 	ValidatorName string
+	RuleID        string // optional: identifies the specific check of the validator that was violated
+
+	// optional structured information; set with the With...() methods
+
+	FieldPath        string            // JSON path of the violating field within the resource, e.g. spec.containers[1].securityContext.privileged
+	ContainerName    string            // the violating container, if the violation is specific to one
+	Remediation      string            // a hint on how to resolve the violation
+	DocumentationURL string            // where to read more about the violated rule
+	Details          map[string]string // any additional, validator-specific information
 }
 
 type AbortFunc func() (bool, error)
+
+/*
+ClientAware is implemented by validators that read from the cluster themselves (e.g. the ConfigMaps of ListConfigMapsConfig),
+rather than only validating the fetched resources.
+SetClientSet is called before each run.
+*/
+type ClientAware interface {
+	SetClientSet(clientSet kubernetes.Interface)
+}
+
+/*
+ScopeAware is implemented by validators that need to tell resources that were scoped out of a run (see RolloutsConfig)
+from resources that don't exist, e.g. in order not to report the entries of lists that refer to them.
+SetScopedOut is called before each run, with the workloads that were scoped out and their dependents.
+*/
+type ScopeAware interface {
+	SetScopedOut(resources []unstructured.Unstructured)
+}
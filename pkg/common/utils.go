@@ -3,11 +3,11 @@ package common
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/selection"
 )
 
 const (
@@ -19,15 +19,30 @@ const (
 	KIND_STATEFUL_SET           = "StatefulSet"
 	KIND_JOB                    = "Job"
 	KIND_CRON_JOB               = "CronJob"
+	KIND_NAMESPACE              = "Namespace"
+	KIND_CONFIG_MAP             = "ConfigMap"
+
+	// DocumentationURL points to the documentation of the built-in validators
+	DocumentationURL = "https://github.com/SAP/k8s-resource-validator/blob/main/docs/DETAILS.md"
 )
 
 var (
+	/*
+		ExemptPodLabelName and ExemptPodLabelValue are the defaults of Config.Exempt.LabelName and Config.Exempt.LabelValue.
+
+		Deprecated: set exempt.labelName and exempt.labelValue (or rather exempt.selectors) in config.yaml, or the Config of a Validation
+	*/
 	ExemptPodLabelName  = "resources.gardener.cloud/managed-by"
 	ExemptPodLabelValue = "gardener"
 
 	errUnableToFindOwner = errors.New("couldn't find owner references")
 )
 
+/*
+GetOwnerReferences returns the owner references of the resource in resources that has the same kind, name and namespace as item.
+
+Deprecated: scans all resources on each call; use ResourceIndex.Owners() instead
+*/
 func GetOwnerReferences(resources []unstructured.Unstructured, item unstructured.Unstructured) ([]metav1.OwnerReference, error) {
 	idx := IndexFunc(resources, func(p unstructured.Unstructured) bool {
 		namespace := p.GetNamespace()
@@ -62,14 +77,12 @@ func IndexFunc[E any](s []E, f func(E) bool) int {
 	return -1
 }
 
+/*
+IsExempt returns true if the resource has the label ExemptPodLabelName with the value ExemptPodLabelValue (see Config.IsExempt()).
+Deprecated: exemptions (including this label) are applied by the validation engine to the violations of all validators.
+*/
 func IsExempt(resource unstructured.Unstructured) bool {
-	// TODO: support multiple keys?
-	key := ExemptPodLabelName
-	values := []string{ExemptPodLabelValue}
-	var requirementLabels labels.Set = resource.GetLabels()
-	requirement, _ := labels.NewRequirement(key, selection.Equals, values)
-	matches := requirement.Matches(requirementLabels)
-	return matches
+	return Config{Exempt: ExemptionConfig{LabelName: ExemptPodLabelName, LabelValue: ExemptPodLabelValue}}.IsExempt(resource)
 }
 
 func NewViolation(resource unstructured.Unstructured, message string, level int, validatorName string) Violation {
@@ -77,11 +90,79 @@ func NewViolation(resource unstructured.Unstructured, message string, level int,
 	return response
 }
 
+func (v Violation) WithRuleID(ruleID string) Violation {
+	v.RuleID = ruleID
+	return v
+}
+
+func (v Violation) WithFieldPath(fieldPath string) Violation {
+	v.FieldPath = fieldPath
+	return v
+}
+
+func (v Violation) WithContainerName(containerName string) Violation {
+	v.ContainerName = containerName
+	return v
+}
+
+func (v Violation) WithRemediation(remediation string) Violation {
+	v.Remediation = remediation
+	return v
+}
+
+func (v Violation) WithDocumentationURL(url string) Violation {
+	v.DocumentationURL = url
+	return v
+}
+
+/*
+WithDetail adds a key-value pair to the violation's details.
+The details map is copied, so that violations derived from the same violation don't share it.
+*/
+func (v Violation) WithDetail(key string, value string) Violation {
+	details := make(map[string]string, len(v.Details)+1)
+	maps.Copy(details, v.Details)
+	details[key] = value
+	v.Details = details
+	return v
+}
+
 type ViolationTarget struct {
-	Kind      string
-	Name      string
-	Namespace string
-	Group     string
+	Kind      string `json:"kind" yaml:"kind"`
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Group     string `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+func NewViolationTarget(resource *unstructured.Unstructured) ViolationTarget {
+	if resource == nil {
+		return ViolationTarget{}
+	}
+
+	return ViolationTarget{
+		Kind:      resource.GetKind(),
+		Name:      resource.GetName(),
+		Namespace: resource.GetNamespace(),
+		Group:     resource.GroupVersionKind().Group,
+	}
+}
+
+/*
+FilterViolationsByNamespace returns the violations whose resource resides in one of the given namespaces.
+If no namespaces are given, all violations are returned.
+*/
+func FilterViolationsByNamespace(violations []Violation, namespaces []string) []Violation {
+	if len(namespaces) == 0 {
+		return violations
+	}
+
+	var response []Violation
+	for _, violation := range violations {
+		if violation.Resource != nil && slices.Contains(namespaces, violation.Resource.GetNamespace()) {
+			response = append(response, violation)
+		}
+	}
+	return response
 }
 
 func GetViolationsGroupedByResource(violations []Violation) [][]Violation {
@@ -0,0 +1,232 @@
+/*
+Package daemon performs validation runs periodically, for long-running (e.g. in-cluster) deployments.
+
+When leader election is enabled, only the replica holding the Lease performs validations.
+*/
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+)
+
+const (
+	defaultHistorySize   = 10
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+type Options struct {
+	Schedule       Schedule                 // when to perform validation runs
+	RunOnStart     bool                     // perform a run as soon as the daemon starts (or becomes leader), regardless of Schedule
+	HistorySize    int                      // number of results retained in memory; defaults to 10
+	LeaderElection *LeaderElectionOptions   // nil disables leader election
+	OnResult       func(*validation.Result) // optional; called after each run
+}
+
+type LeaderElectionOptions struct {
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string // defaults to the host name
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+type Daemon struct {
+	validation *validation.Validation
+	validators []common.Validator
+	options    Options
+	history    *validation.ResultHistory
+	isLeader   atomic.Bool
+	ctx        context.Context
+	logger     logr.Logger
+}
+
+func NewDaemon(ctx context.Context, validationInstance *validation.Validation, validators []common.Validator, options Options) (*Daemon, error) {
+	if validationInstance == nil {
+		return nil, errors.New("validation instance is nil")
+	}
+
+	if options.Schedule == nil {
+		return nil, errEmptySchedule
+	}
+
+	if options.HistorySize == 0 {
+		options.HistorySize = defaultHistorySize
+	}
+
+	if options.LeaderElection != nil {
+		if options.LeaderElection.LeaseName == "" || options.LeaderElection.LeaseNamespace == "" {
+			return nil, errors.New("leader election requires a lease name and namespace")
+		}
+		leaderElectionOptions := *options.LeaderElection
+		if leaderElectionOptions.Identity == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, err
+			}
+			leaderElectionOptions.Identity = hostname
+		}
+		if leaderElectionOptions.LeaseDuration == 0 {
+			leaderElectionOptions.LeaseDuration = defaultLeaseDuration
+		}
+		if leaderElectionOptions.RenewDeadline == 0 {
+			leaderElectionOptions.RenewDeadline = defaultRenewDeadline
+		}
+		if leaderElectionOptions.RetryPeriod == 0 {
+			leaderElectionOptions.RetryPeriod = defaultRetryPeriod
+		}
+		options.LeaderElection = &leaderElectionOptions
+	}
+
+	response := Daemon{
+		validation: validationInstance,
+		validators: validators,
+		options:    options,
+		history:    validation.NewResultHistory(options.HistorySize),
+		ctx:        ctx,
+	}
+
+	var err error
+	response.logger, err = logr.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+/*
+History returns the retained results; it may be shared with e.g. server.Server
+*/
+func (d *Daemon) History() *validation.ResultHistory {
+	return d.history
+}
+
+/*
+Run blocks until ctx is done, performing validation runs according to the schedule
+(only while holding the Lease, if leader election is enabled)
+*/
+func (d *Daemon) Run(ctx context.Context) error {
+	if d.options.LeaderElection == nil {
+		d.runScheduled(ctx)
+		return nil
+	}
+
+	if err := d.validation.Connect(); err != nil {
+		return err
+	}
+
+	// a replica that lost the lease competes for it again, until ctx is done;
+	// a LeaderElector can't be run again, so each attempt uses a new one
+	for ctx.Err() == nil {
+		elector, err := d.newLeaderElector()
+		if err != nil {
+			return err
+		}
+		elector.Run(ctx)
+	}
+
+	return nil
+}
+
+func (d *Daemon) newLeaderElector() (*leaderelection.LeaderElector, error) {
+	leaderElectionOptions := d.options.LeaderElection
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaderElectionOptions.LeaseName,
+			Namespace: leaderElectionOptions.LeaseNamespace,
+		},
+		Client: d.validation.Client.ClientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: leaderElectionOptions.Identity,
+		},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaderElectionOptions.LeaseDuration,
+		RenewDeadline:   leaderElectionOptions.RenewDeadline,
+		RetryPeriod:     leaderElectionOptions.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            leaderElectionOptions.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				d.logger.V(1).Info(fmt.Sprintf("%s started leading", leaderElectionOptions.Identity))
+				d.runScheduled(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				d.logger.V(1).Info(fmt.Sprintf("%s stopped leading", leaderElectionOptions.Identity))
+			},
+		},
+	})
+}
+
+/*
+IsLeader returns true while the daemon performs scheduled runs, i.e. if it is running and either holds the Lease or leader election is disabled
+*/
+func (d *Daemon) IsLeader() bool {
+	return d.isLeader.Load()
+}
+
+/*
+RunOnce performs a single validation run on freshly fetched resources, and records its result
+*/
+func (d *Daemon) RunOnce() *validation.Result {
+	result := d.validation.RefreshAndValidateWithResult(d.validators, nil)
+	d.history.Add(result)
+
+	if result.Outcome == validation.OutcomeAborted {
+		d.logger.V(0).Info(fmt.Sprintf("validation run was aborted: %s", result.AbortReason))
+	} else {
+		d.logger.V(1).Info(fmt.Sprintf("validation run %s with %d violations and %d errors", strings.ToLower(string(result.Outcome)), len(result.Violations), len(result.Errors)))
+	}
+
+	if d.options.OnResult != nil {
+		d.options.OnResult(result)
+	}
+
+	return result
+}
+
+func (d *Daemon) runScheduled(ctx context.Context) {
+	d.isLeader.Store(true)
+	defer d.isLeader.Store(false)
+
+	if d.options.RunOnStart {
+		d.RunOnce()
+	}
+
+	for {
+		next := d.options.Schedule.Next(time.Now())
+		if next.IsZero() {
+			d.logger.Error(errors.New("schedule has no upcoming activation"), "stopped scheduling validation runs")
+			<-ctx.Done()
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			d.RunOnce()
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/testr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclient "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+	"github.com/SAP/k8s-resource-validator/pkg/validators/fake"
+)
+
+var (
+	ctx                context.Context
+	appFs              afero.Fs
+	logger             logr.Logger
+	validationInstance *validation.Validation
+	clientSet          *k8sfake.Clientset
+	validators         []common.Validator
+)
+
+func TestDaemon(t *testing.T) {
+	RegisterFailHandler(Fail)
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	reporterConfig.JUnitReport = "tests.xml"
+	RunSpecs(t, "Daemon Test Suite", suiteConfig, reporterConfig)
+}
+
+var _ = Describe("Schedule", func() {
+	reference := time.Date(2023, time.June, 15, 10, 30, 0, 0, time.UTC) // a Thursday
+
+	DescribeTable("next activation", func(expression string, expected time.Time) {
+		schedule, err := ParseSchedule(expression)
+		Expect(err).To(Succeed())
+		Expect(schedule.Next(reference)).To(Equal(expected))
+	},
+		Entry("interval", "@every 90m", reference.Add(90*time.Minute)),
+		Entry("every minute", "* * * * *", reference.Add(time.Minute)),
+		Entry("hourly", "@hourly", time.Date(2023, time.June, 15, 11, 0, 0, 0, time.UTC)),
+		Entry("daily", "@daily", time.Date(2023, time.June, 16, 0, 0, 0, 0, time.UTC)),
+		Entry("step", "*/20 * * * *", time.Date(2023, time.June, 15, 10, 40, 0, 0, time.UTC)),
+		Entry("list and range", "15 8-9,18 * * *", time.Date(2023, time.June, 15, 18, 15, 0, 0, time.UTC)),
+		Entry("day of week", "0 6 * * 1", time.Date(2023, time.June, 19, 6, 0, 0, 0, time.UTC)),
+		Entry("sunday as 7", "0 6 * * 7", time.Date(2023, time.June, 18, 6, 0, 0, 0, time.UTC)),
+		Entry("day of month or day of week", "0 0 1 * 5", time.Date(2023, time.June, 16, 0, 0, 0, 0, time.UTC)),
+		Entry("day of month step and day of week", "0 0 */2 * 1", time.Date(2023, time.June, 19, 0, 0, 0, 0, time.UTC)),
+		Entry("day of month and day of week step", "0 0 16-18 * */3", time.Date(2023, time.June, 17, 0, 0, 0, 0, time.UTC)),
+		Entry("month", "0 0 1 9 *", time.Date(2023, time.September, 1, 0, 0, 0, 0, time.UTC)),
+	)
+
+	DescribeTable("invalid expressions", func(expression string) {
+		_, err := ParseSchedule(expression)
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("empty", ""),
+		Entry("too few fields", "* * * *"),
+		Entry("out of range", "60 * * * *"),
+		Entry("invalid step", "*/0 * * * *"),
+		Entry("not a number", "a * * * *"),
+		Entry("invalid interval", "@every soon"),
+		Entry("negative interval", "@every -1m"),
+	)
+
+	It("unsatisfiable expression", func() {
+		schedule, err := ParseSchedule("0 0 30 2 *")
+		Expect(err).To(Succeed())
+		Expect(schedule.Next(reference).IsZero()).To(BeTrue())
+	})
+})
+
+var _ = Describe("Daemon", func() {
+	BeforeEach(func() {
+		os.Unsetenv("CONFIG_DIR")
+		ctx = context.Background()
+		appFs = afero.NewMemMapFs()
+		logger = testr.New(&testing.T{})
+		ctx = logr.NewContext(ctx, logger)
+		ctx = context.WithValue(ctx, common.FileSystemContextKey, appFs)
+
+		scheme := runtime.NewScheme()
+		_ = appsv1.AddToScheme(scheme)
+		_ = corev1.AddToScheme(scheme)
+		_ = batchv1.AddToScheme(scheme)
+
+		var err error
+		validationInstance, err = validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		clientSet = k8sfake.NewSimpleClientset()
+		validationInstance.SetClient(&validation.K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: clientSet,
+		})
+
+		fakeValidator, err := fake.NewFakeValidator(ctx, 1, false)
+		Expect(err).To(Succeed())
+		validators = []common.Validator{fakeValidator}
+	})
+
+	It("requires a schedule", func() {
+		_, err := NewDaemon(ctx, validationInstance, validators, Options{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("run once records the result", func() {
+		schedule, _ := NewIntervalSchedule(time.Hour)
+		daemon, err := NewDaemon(ctx, validationInstance, validators, Options{Schedule: schedule})
+		Expect(err).To(Succeed())
+
+		daemon.RunOnce()
+		Expect(validationInstance.PreValidated).To(BeTrue())
+
+		result := daemon.RunOnce()
+		Expect(result.Violations).To(HaveLen(1))
+		Expect(daemon.History().All()).To(HaveLen(2))
+	})
+
+	It("retains the last N results", func() {
+		schedule, _ := NewIntervalSchedule(10 * time.Millisecond)
+		daemon, err := NewDaemon(ctx, validationInstance, validators, Options{Schedule: schedule, HistorySize: 2})
+		Expect(err).To(Succeed())
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = daemon.Run(runCtx)
+		}()
+
+		Eventually(func() int { return len(daemon.History().All()) }).Should(Equal(2))
+		cancel()
+		Eventually(done).Should(BeClosed())
+		Expect(daemon.IsLeader()).To(BeFalse())
+	})
+
+	It("runs only while holding the lease", func() {
+		schedule, _ := NewIntervalSchedule(time.Hour)
+		results := make(chan *validation.Result, 1)
+		options := Options{
+			Schedule:   schedule,
+			RunOnStart: true,
+			OnResult: func(result *validation.Result) {
+				results <- result
+			},
+			LeaderElection: &LeaderElectionOptions{
+				LeaseName:      "validator",
+				LeaseNamespace: "default",
+				Identity:       "replica-1",
+				LeaseDuration:  2 * time.Second,
+				RenewDeadline:  time.Second,
+				RetryPeriod:    100 * time.Millisecond,
+			},
+		}
+		daemon, err := NewDaemon(ctx, validationInstance, validators, options)
+		Expect(err).To(Succeed())
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = daemon.Run(runCtx)
+		}()
+
+		Eventually(results).Should(Receive())
+		Expect(daemon.IsLeader()).To(BeTrue())
+
+		lease, err := clientSet.CoordinationV1().Leases("default").Get(ctx, "validator", metav1.GetOptions{})
+		Expect(err).To(Succeed())
+		Expect(*lease.Spec.HolderIdentity).To(Equal("replica-1"))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,213 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron schedules are evaluated minute by minute for at most this long before giving up
+const maxScheduleLookahead = 5 * 366 * 24 * time.Hour
+
+var errEmptySchedule = errors.New("schedule is empty")
+
+/*
+Schedule determines when validation runs take place
+*/
+type Schedule interface {
+	// Next returns the earliest activation time after t
+	Next(t time.Time) time.Time
+}
+
+/*
+ParseSchedule parses either a standard 5-field cron expression (minute, hour, day of month, month, day of week),
+a descriptor such as "@hourly" or "@daily", or a fixed interval in the form "@every <duration>" (e.g. "@every 30m").
+*/
+func ParseSchedule(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, errEmptySchedule
+	}
+
+	if interval, found := strings.CutPrefix(expression, "@every "); found {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", interval, err)
+		}
+		return NewIntervalSchedule(duration)
+	}
+
+	switch expression {
+	case "@yearly", "@annually":
+		expression = "0 0 1 1 *"
+	case "@monthly":
+		expression = "0 0 1 * *"
+	case "@weekly":
+		expression = "0 0 * * 0"
+	case "@daily", "@midnight":
+		expression = "0 0 * * *"
+	case "@hourly":
+		expression = "0 * * * *"
+	}
+
+	return parseCronSchedule(expression)
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func NewIntervalSchedule(interval time.Duration) (Schedule, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %s", interval)
+	}
+	return intervalSchedule{interval: interval}, nil
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// each field is a bit set of the values it matches; day of month and day of week are restricted unless they start with "*" (see matchesDay)
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	dayOfMonthRestricted, dayOfWeekRestricted  bool
+}
+
+func parseCronSchedule(expression string) (Schedule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields, got %d", expression, len(cronFields), len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		var err error
+		bits[i], err = parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expression, err)
+		}
+	}
+
+	// Sunday may be given as either 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return cronSchedule{
+		minute:               bits[0],
+		hour:                 bits[1],
+		dayOfMonth:           bits[2],
+		month:                bits[3],
+		dayOfWeek:            bits[4],
+		dayOfMonthRestricted: !strings.HasPrefix(parts[2], "*"),
+		dayOfWeekRestricted:  !strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+	max := field.max
+	if field.name == "day of week" {
+		max = 7
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, field.name)
+			}
+		}
+
+		low, high := field.min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			low, err = strconv.Atoi(lowPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", lowPart, field.name)
+			}
+			high = low
+			if isRange {
+				high, err = strconv.Atoi(highPart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s field", highPart, field.name)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+
+		if low < field.min || high > max || low > high {
+			return 0, fmt.Errorf("value %q out of range [%d-%d] in %s field", rangePart, field.min, max, field.name)
+		}
+
+		for i := low; i <= high; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func (s cronSchedule) Next(t time.Time) time.Time {
+	// cron has a resolution of one minute
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	deadline := t.Add(maxScheduleLookahead)
+
+	for t.Before(deadline) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	// the expression can never be satisfied (e.g. February 30th)
+	return time.Time{}
+}
+
+/*
+as in standard cron: if both day of month and day of week are restricted (i.e. they don't start with "*", not even with a step),
+a day matching either of them matches; otherwise, a day has to match both
+*/
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonthMatches := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeekMatches := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.dayOfMonthRestricted && s.dayOfWeekRestricted {
+		return dayOfMonthMatches || dayOfWeekMatches
+	}
+	return dayOfMonthMatches && dayOfWeekMatches
+}
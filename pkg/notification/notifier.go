@@ -0,0 +1,279 @@
+/*
+Package notification posts webhook notifications (e.g. to Slack or Teams) when violations appear or are resolved.
+
+Only changes are notified: the violations of each validation result are compared, by fingerprint, to those of the previous result.
+*/
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/afero"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = time.Second
+
+	/*
+		DefaultTemplate renders a payload that is understood by Slack and Teams incoming webhooks (the "text" field),
+		while also carrying the changed violations in a machine-readable form
+	*/
+	DefaultTemplate = `{"text": {{ printf "%d new and %d resolved violations" (len .Added) (len .Resolved) | json }}, "added": {{ json .Added }}, "resolved": {{ json .Resolved }}}`
+)
+
+// WebhookConfig configures a webhook (see common.WebhookConfig)
+type WebhookConfig = common.WebhookConfig
+
+// Config configures notifications (see common.NotificationConfig)
+type Config = common.NotificationConfig
+
+/*
+TemplateData is passed to the webhook templates.
+Templates may use the "json" function to render any value as JSON.
+*/
+type TemplateData struct {
+	Added    []validation.ViolationReport
+	Resolved []validation.ViolationReport
+	Result   *validation.Result
+}
+
+type webhook struct {
+	config   WebhookConfig
+	template *template.Template
+}
+
+type Notifier struct {
+	config       Config
+	maxRetries   int           // of config, or the default
+	retryBackoff time.Duration // of config, or the default
+	webhooks     []webhook
+	client       *http.Client
+	previous     []validation.ViolationReport
+	hasPrevious  bool
+	appFs        afero.Fs
+	ctx          context.Context
+	logger       logr.Logger
+}
+
+func NewNotifier(ctx context.Context, config Config) (*Notifier, error) {
+	timeout := valueOrDefault(config.Timeout, defaultTimeout)
+	if timeout < 0 {
+		return nil, fmt.Errorf("timeout is negative: %s", timeout)
+	}
+
+	response := Notifier{
+		config:       config,
+		maxRetries:   valueOrDefault(config.MaxRetries, defaultMaxRetries),
+		retryBackoff: valueOrDefault(config.RetryBackoff, defaultRetryBackoff),
+		client:       &http.Client{Timeout: timeout},
+		ctx:          ctx,
+	}
+	if response.maxRetries < 0 {
+		return nil, fmt.Errorf("maxRetries is negative: %d", response.maxRetries)
+	}
+	if response.retryBackoff < 0 {
+		return nil, fmt.Errorf("retryBackoff is negative: %s", response.retryBackoff)
+	}
+
+	var err error
+	response.logger, err = logr.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, webhookConfig := range config.Webhooks {
+		if webhookConfig.URL == "" {
+			return nil, fmt.Errorf("webhook %d: url is empty", i)
+		}
+
+		templateText := webhookConfig.Template
+		if templateText == "" {
+			templateText = DefaultTemplate
+		}
+
+		parsedTemplate, err := template.New(webhookConfig.URL).Funcs(template.FuncMap{"json": toJSON}).Parse(templateText)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %d: invalid template: %w", i, err)
+		}
+
+		response.webhooks = append(response.webhooks, webhook{config: webhookConfig, template: parsedTemplate})
+	}
+
+	if config.StateFile != "" {
+		response.appFs = ctx.Value(common.FileSystemContextKey).(afero.Fs)
+		if err := response.readState(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+/*
+Notify compares the violations of result to those of the previously notified result,
+and posts the added and resolved violations (if any) to all webhooks.
+
+Only completed runs are compared: the violations of failed runs may be incomplete, and aborted runs have none,
+so that comparing them would report all violations as resolved.
+*/
+func (n *Notifier) Notify(result *validation.Result) error {
+	if result == nil || len(result.Errors) > 0 || result.Outcome == validation.OutcomeAborted {
+		return nil
+	}
+
+	current := result.Violations
+
+	if !n.hasPrevious && !n.config.NotifyInitialViolations {
+		n.logger.V(2).Info(fmt.Sprintf("recorded %d violations as the initial state", len(current)))
+		return n.setPrevious(current)
+	}
+
+	diff := validation.DiffReports(n.previous, current)
+	data := TemplateData{
+		Added:    diff.Added,
+		Resolved: diff.Resolved,
+		Result:   result,
+	}
+
+	if len(data.Added) == 0 && len(data.Resolved) == 0 {
+		n.logger.V(2).Info("no violations were added or resolved")
+		return n.setPrevious(current)
+	}
+
+	var cumulativeErr error
+	for _, hook := range n.webhooks {
+		if err := n.post(hook, data); err != nil {
+			n.logger.Error(err, "couldn't notify webhook", "url", hook.config.URL)
+			cumulativeErr = errors.Join(cumulativeErr, err)
+		}
+	}
+
+	// the state advances even if a webhook failed, so that it is not flooded with the same changes on each run
+	return errors.Join(cumulativeErr, n.setPrevious(current))
+}
+
+func (n *Notifier) post(hook webhook, data TemplateData) error {
+	var payload bytes.Buffer
+	if err := hook.template.Execute(&payload, data); err != nil {
+		return fmt.Errorf("couldn't render template for %s: %w", hook.config.URL, err)
+	}
+	if !json.Valid(payload.Bytes()) {
+		return fmt.Errorf("template for %s rendered invalid JSON", hook.config.URL)
+	}
+
+	backoff := n.retryBackoff
+	var err error
+	for attempt := 0; attempt <= n.maxRetries; attempt++ {
+		if attempt > 0 {
+			n.logger.V(2).Info(fmt.Sprintf("retrying webhook %s in %s", hook.config.URL, backoff))
+			select {
+			case <-n.ctx.Done():
+				return n.ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var retryable bool
+		retryable, err = n.send(hook, payload.Bytes())
+		if err == nil || !retryable {
+			return err
+		}
+	}
+
+	return err
+}
+
+/*
+returns whether a failed request should be retried
+*/
+func (n *Notifier) send(hook webhook, payload []byte) (bool, error) {
+	request, err := http.NewRequestWithContext(n.ctx, http.MethodPost, hook.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range hook.config.Headers {
+		request.Header.Set(name, os.ExpandEnv(value)) // e.g. "Bearer ${WEBHOOK_TOKEN}"
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook %s responded with status %d", hook.config.URL, response.StatusCode)
+	retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retryable, err
+}
+
+func (n *Notifier) setPrevious(current []validation.ViolationReport) error {
+	n.previous = current
+	n.hasPrevious = true
+	return n.writeState()
+}
+
+func (n *Notifier) readState() error {
+	content, err := afero.ReadFile(n.appFs, n.config.StateFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if err := json.Unmarshal(content, &n.previous); err != nil {
+		return fmt.Errorf("couldn't parse notification state file %s: %w", n.config.StateFile, err)
+	}
+	n.hasPrevious = true
+	return nil
+}
+
+func (n *Notifier) writeState() error {
+	if n.config.StateFile == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(n.previous)
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(n.appFs, n.config.StateFile, content, 0644)
+}
+
+func toJSON(value interface{}) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// valueOrDefault returns the configured value, or defaultValue if it isn't configured
+func valueOrDefault[T any](value *T, defaultValue T) T {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
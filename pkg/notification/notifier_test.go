@@ -0,0 +1,228 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/testr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+)
+
+var (
+	ctx    context.Context
+	appFs  afero.Fs
+	logger logr.Logger
+)
+
+func TestNotification(t *testing.T) {
+	RegisterFailHandler(Fail)
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	reporterConfig.JUnitReport = "tests.xml"
+	RunSpecs(t, "Notification Test Suite", suiteConfig, reporterConfig)
+}
+
+func newResult(podNames ...string) *validation.Result {
+	var violations []common.Violation
+	for _, name := range podNames {
+		resource := unstructured.Unstructured{}
+		resource.SetKind(common.KIND_POD)
+		resource.SetName(name)
+		resource.SetNamespace("namespace")
+		violations = append(violations, common.NewViolation(resource, "message", 1, "validator"))
+	}
+	return validation.NewResult(time.Now(), nil, nil, violations, nil)
+}
+
+type recordingHandler struct {
+	mutex        sync.Mutex
+	payloads     []map[string]interface{}
+	failuresLeft int
+}
+
+func (h *recordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.failuresLeft > 0 {
+		h.failuresLeft--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	payload := map[string]interface{}{}
+	_ = json.Unmarshal(body, &payload)
+	h.payloads = append(h.payloads, payload)
+}
+
+func (h *recordingHandler) received() []map[string]interface{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.payloads
+}
+
+var _ = Describe("Notifier", func() {
+	var (
+		handler    *recordingHandler
+		testServer *httptest.Server
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		appFs = afero.NewMemMapFs()
+		logger = testr.New(&testing.T{})
+		ctx = logr.NewContext(ctx, logger)
+		ctx = context.WithValue(ctx, common.FileSystemContextKey, appFs)
+
+		handler = &recordingHandler{}
+		testServer = httptest.NewServer(handler)
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("notifies only changes", func() {
+		notifier, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL}}})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a", "b"))).To(Succeed())
+		Expect(handler.received()).To(BeEmpty())
+
+		Expect(notifier.Notify(newResult("a", "b"))).To(Succeed())
+		Expect(handler.received()).To(BeEmpty())
+
+		Expect(notifier.Notify(newResult("b", "c"))).To(Succeed())
+		Expect(handler.received()).To(HaveLen(1))
+
+		payload := handler.received()[0]
+		Expect(payload["text"]).To(Equal("1 new and 1 resolved violations"))
+		Expect(payload["added"]).To(HaveLen(1))
+		Expect(payload["added"].([]interface{})[0].(map[string]interface{})["resource"].(map[string]interface{})["name"]).To(Equal("c"))
+		Expect(payload["resolved"].([]interface{})[0].(map[string]interface{})["resource"].(map[string]interface{})["name"]).To(Equal("a"))
+	})
+
+	It("notifies initial violations if configured", func() {
+		notifier, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL}}, NotifyInitialViolations: true})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+		Expect(handler.received()).To(HaveLen(1))
+	})
+
+	It("ignores results with errors", func() {
+		notifier, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL}}, NotifyInitialViolations: true})
+		Expect(err).To(Succeed())
+
+		result := newResult("a")
+		result.Errors = []string{"failed"}
+		Expect(notifier.Notify(result)).To(Succeed())
+		Expect(handler.received()).To(BeEmpty())
+	})
+
+	It("ignores aborted results", func() {
+		notifier, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL}}})
+		Expect(err).To(Succeed())
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+
+		// an aborted run has no violations, which mustn't be reported as resolved
+		aborted := newResult()
+		aborted.Outcome = validation.OutcomeAborted
+		aborted.AbortReason = "frozen"
+		Expect(notifier.Notify(aborted)).To(Succeed())
+		Expect(handler.received()).To(BeEmpty())
+
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+		Expect(handler.received()).To(BeEmpty())
+	})
+
+	It("custom template", func() {
+		template := `{"summary": "{{ len .Added }}/{{ len .Resolved }}", "first": {{ json (index .Added 0).Resource.Name }}}`
+		notifier, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL, Template: template}}, NotifyInitialViolations: true})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+		Expect(handler.received()[0]["summary"]).To(Equal("1/0"))
+		Expect(handler.received()[0]["first"]).To(Equal("a"))
+	})
+
+	It("invalid template", func() {
+		_, err := NewNotifier(ctx, Config{Webhooks: []WebhookConfig{{URL: testServer.URL, Template: "{{ .Added"}}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("retries failed requests", func() {
+		handler.failuresLeft = 2
+		retryBackoff := time.Millisecond
+		notifier, err := NewNotifier(ctx, Config{
+			Webhooks:                []WebhookConfig{{URL: testServer.URL}},
+			NotifyInitialViolations: true,
+			RetryBackoff:            &retryBackoff,
+		})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+		Expect(handler.received()).To(HaveLen(1))
+	})
+
+	It("gives up after max retries", func() {
+		handler.failuresLeft = 5
+		maxRetries, retryBackoff := 2, time.Millisecond
+		notifier, err := NewNotifier(ctx, Config{
+			Webhooks:                []WebhookConfig{{URL: testServer.URL}},
+			NotifyInitialViolations: true,
+			MaxRetries:              &maxRetries,
+			RetryBackoff:            &retryBackoff,
+		})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a"))).To(HaveOccurred())
+		Expect(handler.received()).To(BeEmpty())
+	})
+
+	It("retries can be turned off", func() {
+		handler.failuresLeft = 1
+		maxRetries := 0
+		notifier, err := NewNotifier(ctx, Config{
+			Webhooks:                []WebhookConfig{{URL: testServer.URL}},
+			NotifyInitialViolations: true,
+			MaxRetries:              &maxRetries,
+		})
+		Expect(err).To(Succeed())
+
+		Expect(notifier.Notify(newResult("a"))).To(HaveOccurred())
+		Expect(handler.received()).To(BeEmpty()) // the request would have succeeded when retried
+
+		maxRetries = -1
+		_, err = NewNotifier(ctx, Config{MaxRetries: &maxRetries})
+		Expect(err).To(MatchError(ContainSubstring("maxRetries is negative")))
+	})
+
+	It("persists state across notifiers", func() {
+		config := Config{Webhooks: []WebhookConfig{{URL: testServer.URL}}, StateFile: "/state/notifications.json"}
+
+		notifier, err := NewNotifier(ctx, config)
+		Expect(err).To(Succeed())
+		Expect(notifier.Notify(newResult("a"))).To(Succeed())
+
+		notifier, err = NewNotifier(ctx, config)
+		Expect(err).To(Succeed())
+		Expect(notifier.Notify(newResult("a", "b"))).To(Succeed())
+
+		Expect(handler.received()).To(HaveLen(1))
+		Expect(handler.received()[0]["text"]).To(Equal("1 new and 0 resolved violations"))
+	})
+})
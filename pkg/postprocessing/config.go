@@ -0,0 +1,55 @@
+package postprocessing
+
+import (
+	"fmt"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+/*
+Config configures a built-in post-processor (see common.PostProcessorConfig)
+*/
+type Config = common.PostProcessorConfig
+
+/*
+NewPostProcessors creates the configured post-processors, in order
+*/
+func NewPostProcessors(configs []Config) ([]common.PostProcessor, error) {
+	response := make([]common.PostProcessor, 0, len(configs))
+	for i, config := range configs {
+		for j, severity := range config.Match.Severities {
+			parsed, err := common.ParseSeverity(string(severity))
+			if err != nil {
+				return nil, errInvalidConfig(i, config.Type, "%s", err)
+			}
+			config.Match.Severities[j] = parsed
+		}
+
+		switch config.Type {
+		case FilterName:
+			response = append(response, NewFilter(config.Match, config.OnlyIfSole))
+		case EscalationName:
+			severity, err := common.ParseSeverity(string(config.Severity))
+			if err != nil {
+				return nil, errInvalidConfig(i, config.Type, "%s", err)
+			}
+			response = append(response, NewEscalation(config.Match, config.MinViolationsPerResource, severity))
+		case RollUpName:
+			response = append(response, NewRollUp(config.Match))
+		case MergeName:
+			response = append(response, NewMerge())
+		case LimitName:
+			if config.Max < 1 {
+				return nil, errInvalidConfig(i, config.Type, "max must be positive")
+			}
+			response = append(response, NewLimit(config.Max))
+		default:
+			return nil, errInvalidConfig(i, config.Type, "unknown type")
+		}
+	}
+	return response, nil
+}
+
+func errInvalidConfig(index int, processorType string, format string, args ...any) error {
+	return fmt.Errorf("post-processor %d (%s): %s", index, processorType, fmt.Sprintf(format, args...))
+}
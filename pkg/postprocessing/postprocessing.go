@@ -0,0 +1,246 @@
+/*
+Package postprocessing contains built-in post-processors, which transform the violations of a validation run before they are returned.
+
+Post-processors are applied in order, e.g. a filter, followed by an escalation and a merge:
+
+	validationInstance.SetPostProcessors(
+		postprocessing.NewFilter(postprocessing.Selector{Validators: []string{privileged_pods.ValidatorName}}, true),
+		postprocessing.NewEscalation(postprocessing.Selector{}, 2, common.SeverityCritical),
+		postprocessing.NewMerge(),
+	)
+*/
+package postprocessing
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	FilterName     = "filter"
+	EscalationName = "escalate"
+	MergeName      = "merge"
+	LimitName      = "limit"
+)
+
+/*
+Selector selects violations by their properties (see common.ViolationSelector)
+*/
+type Selector = common.ViolationSelector
+
+/*
+Filter removes the selected violations
+*/
+type Filter struct {
+	selector Selector
+	/*
+		onlyIfSole removes the selected violations of a resource only if the resource has no other (unselected) violations.
+		E.g. a privileged pod may be acceptable, unless it also violates other validators.
+	*/
+	onlyIfSole bool
+}
+
+func NewFilter(selector Selector, onlyIfSole bool) common.PostProcessor {
+	return &Filter{selector: selector, onlyIfSole: onlyIfSole}
+}
+
+func (p *Filter) GetName() string {
+	return FilterName
+}
+
+func (p *Filter) Process(violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	unselected := make(map[common.ViolationTarget]int)
+	for _, violation := range violations {
+		if !p.selector.Matches(violation) {
+			unselected[common.NewViolationTarget(violation.Resource)]++
+		}
+	}
+
+	var response []common.Violation
+	for _, violation := range violations {
+		if p.selector.Matches(violation) && (!p.onlyIfSole || unselected[common.NewViolationTarget(violation.Resource)] == 0) {
+			continue
+		}
+		response = append(response, violation)
+	}
+	return response
+}
+
+/*
+Escalation raises the severity of the selected violations, whose resource has at least a minimal number of violations.
+Violations that are already more severe are not changed.
+*/
+type Escalation struct {
+	selector                 Selector
+	minViolationsPerResource int
+	severity                 common.Severity
+}
+
+/*
+NewEscalation returns an Escalation; minViolationsPerResource below 1 is treated as 1 (i.e. all selected violations are escalated)
+*/
+func NewEscalation(selector Selector, minViolationsPerResource int, severity common.Severity) common.PostProcessor {
+	return &Escalation{selector: selector, minViolationsPerResource: max(minViolationsPerResource, 1), severity: severity}
+}
+
+func (p *Escalation) GetName() string {
+	return EscalationName
+}
+
+func (p *Escalation) Process(violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	counts := countPerResource(violations)
+
+	response := make([]common.Violation, 0, len(violations))
+	for _, violation := range violations {
+		if p.selector.Matches(violation) &&
+			counts[common.NewViolationTarget(violation.Resource)] >= p.minViolationsPerResource &&
+			violation.Level > p.severity.Level() {
+			violation = violation.WithSeverity(p.severity)
+		}
+		response = append(response, violation)
+	}
+	return response
+}
+
+/*
+Merge combines the violations of each resource into a single violation, with the severity of the most severe of them.
+The merged violation's validator name is the concatenation of the original validator names (e.g. "built-in:freshness+built-in:privileged-pods"),
+and so are its rule IDs; its other structured fields (field paths, container names, remediations, details etc.) list the distinct values of the originals.
+Resources with a single violation are not changed.
+*/
+type Merge struct{}
+
+func NewMerge() common.PostProcessor {
+	return &Merge{}
+}
+
+func (p *Merge) GetName() string {
+	return MergeName
+}
+
+func (p *Merge) Process(violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	var order []common.ViolationTarget
+	grouped := make(map[common.ViolationTarget][]common.Violation)
+	for _, violation := range violations {
+		target := common.NewViolationTarget(violation.Resource)
+		if _, found := grouped[target]; !found {
+			order = append(order, target)
+		}
+		grouped[target] = append(grouped[target], violation)
+	}
+
+	response := make([]common.Violation, 0, len(order))
+	for _, target := range order {
+		response = append(response, merge(grouped[target]))
+	}
+	return response
+}
+
+func merge(violations []common.Violation) common.Violation {
+	if len(violations) == 1 {
+		return violations[0]
+	}
+
+	var validatorNames, ruleIDs, messages, fieldPaths, containerNames, remediations, documentationURLs []string
+	details := make(map[string][]string)
+	level := violations[0].Level
+	for _, violation := range violations {
+		validatorNames = appendDistinct(validatorNames, violation.ValidatorName)
+		ruleIDs = appendDistinct(ruleIDs, violation.RuleID)
+		messages = append(messages, violation.Message)
+		fieldPaths = appendDistinct(fieldPaths, violation.FieldPath)
+		containerNames = appendDistinct(containerNames, violation.ContainerName)
+		remediations = appendDistinct(remediations, violation.Remediation)
+		documentationURLs = appendDistinct(documentationURLs, violation.DocumentationURL)
+		for key, value := range violation.Details {
+			details[key] = appendDistinct(details[key], value)
+		}
+		level = min(level, violation.Level)
+	}
+
+	response := common.Violation{
+		Resource:         violations[0].Resource,
+		Level:            level,
+		ValidatorName:    strings.Join(validatorNames, "+"),
+		RuleID:           strings.Join(ruleIDs, "+"),
+		Message:          strings.Join(messages, "; "),
+		FieldPath:        strings.Join(fieldPaths, ", "),
+		ContainerName:    strings.Join(containerNames, ", "),
+		Remediation:      strings.Join(remediations, "; "),
+		DocumentationURL: strings.Join(documentationURLs, " "),
+	}
+	for key, values := range details {
+		response = response.WithDetail(key, strings.Join(values, ", "))
+	}
+	return response.WithDetail("mergedViolations", strconv.Itoa(len(violations)))
+}
+
+// appendDistinct appends a non-empty value, unless it's already contained
+func appendDistinct(values []string, value string) []string {
+	if value == "" || slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+/*
+Limit keeps the most severe violations, up to a maximal number.
+The order of the kept violations is preserved.
+*/
+type Limit struct {
+	maxViolations int
+}
+
+func NewLimit(maxViolations int) common.PostProcessor {
+	return &Limit{maxViolations: maxViolations}
+}
+
+func (p *Limit) GetName() string {
+	return LimitName
+}
+
+func (p *Limit) Process(violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	if len(violations) <= p.maxViolations {
+		return violations
+	}
+
+	indexes := make([]int, len(violations))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return cmp.Compare(violations[a].Level, violations[b].Level)
+	})
+	indexes = indexes[:p.maxViolations]
+	slices.Sort(indexes)
+
+	response := make([]common.Violation, 0, p.maxViolations)
+	for _, i := range indexes {
+		response = append(response, violations[i])
+	}
+	return response
+}
+
+func countPerResource(violations []common.Violation) map[common.ViolationTarget]int {
+	response := make(map[common.ViolationTarget]int)
+	for _, violation := range violations {
+		response[common.NewViolationTarget(violation.Resource)]++
+	}
+	return response
+}
+
+/*
+Apply runs the post-processors in order
+*/
+func Apply(postProcessors []common.PostProcessor, violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	for _, postProcessor := range postProcessors {
+		violations = postProcessor.Process(violations, resources)
+	}
+	return violations
+}
@@ -0,0 +1,246 @@
+package postprocessing
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	validatorA = "validator-a"
+	validatorB = "validator-b"
+)
+
+func TestPostProcessing(t *testing.T) {
+	RegisterFailHandler(Fail)
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	reporterConfig.JUnitReport = "tests.xml"
+	RunSpecs(t, "Post-Processing Test Suite", suiteConfig, reporterConfig)
+}
+
+func newViolation(podName string, validatorName string, severity common.Severity) common.Violation {
+	resource := unstructured.Unstructured{}
+	resource.SetKind(common.KIND_POD)
+	resource.SetName(podName)
+	resource.SetNamespace("namespace")
+	return common.NewViolation(resource, validatorName+" message", severity.Level(), validatorName)
+}
+
+func names(violations []common.Violation) []string {
+	var response []string
+	for _, violation := range violations {
+		response = append(response, violation.Resource.GetName()+"/"+violation.ValidatorName)
+	}
+	return response
+}
+
+var _ = Describe("Post-Processing", func() {
+	var violations []common.Violation
+
+	BeforeEach(func() {
+		violations = []common.Violation{
+			newViolation("a", validatorA, common.SeverityHigh),
+			newViolation("b", validatorA, common.SeverityLow),
+			newViolation("b", validatorB, common.SeverityMedium),
+			newViolation("c", validatorB, common.SeverityInfo),
+		}
+	})
+
+	It("selector", func() {
+		Expect(Selector{}.Matches(violations[0])).To(BeTrue())
+		Expect(Selector{Validators: []string{validatorB}}.Matches(violations[0])).To(BeFalse())
+		Expect(Selector{Validators: []string{validatorA}, Severities: []common.Severity{common.SeverityHigh}}.Matches(violations[0])).To(BeTrue())
+		Expect(Selector{Namespaces: []string{"other"}}.Matches(violations[0])).To(BeFalse())
+	})
+
+	It("filter", func() {
+		processed := NewFilter(Selector{Validators: []string{validatorA}}, false).Process(violations, nil)
+		Expect(names(processed)).To(Equal([]string{"b/" + validatorB, "c/" + validatorB}))
+	})
+
+	It("filter only sole violations", func() {
+		processed := NewFilter(Selector{Validators: []string{validatorA}}, true).Process(violations, nil)
+		Expect(names(processed)).To(Equal([]string{"b/" + validatorA, "b/" + validatorB, "c/" + validatorB}))
+	})
+
+	It("escalate resources with several violations", func() {
+		processed := NewEscalation(Selector{}, 2, common.SeverityCritical).Process(violations, nil)
+		Expect(processed[0].Severity()).To(Equal(common.SeverityHigh))
+		Expect(processed[1].Severity()).To(Equal(common.SeverityCritical))
+		Expect(processed[2].Severity()).To(Equal(common.SeverityCritical))
+		Expect(processed[3].Severity()).To(Equal(common.SeverityInfo))
+	})
+
+	It("escalation doesn't lower severities", func() {
+		processed := NewEscalation(Selector{}, 0, common.SeverityMedium).Process(violations, nil)
+		Expect(processed[0].Severity()).To(Equal(common.SeverityHigh))
+		Expect(processed[3].Severity()).To(Equal(common.SeverityMedium))
+	})
+
+	It("merge per resource", func() {
+		processed := NewMerge().Process(violations, nil)
+		Expect(processed).To(HaveLen(3))
+		Expect(processed[0]).To(Equal(violations[0]))
+		Expect(processed[1].ValidatorName).To(Equal(validatorA + "+" + validatorB))
+		Expect(processed[1].Message).To(Equal(validatorA + " message; " + validatorB + " message"))
+		Expect(processed[1].Severity()).To(Equal(common.SeverityMedium))
+		Expect(processed[1].Details).To(HaveKeyWithValue("mergedViolations", "2"))
+	})
+
+	It("merge keeps the structured fields", func() {
+		violations[1] = violations[1].WithRuleID("rule-a").WithFieldPath("spec.containers[0].image").WithContainerName("app").WithDetail("age", "48h")
+		violations[2] = violations[2].WithRuleID("rule-b").WithFieldPath("spec.containers[1].image").WithContainerName("app").WithDetail("age", "72h")
+		processed := NewMerge().Process(violations, nil)
+		Expect(processed[1].RuleID).To(Equal("rule-a+rule-b"))
+		Expect(processed[1].FieldPath).To(Equal("spec.containers[0].image, spec.containers[1].image"))
+		Expect(processed[1].ContainerName).To(Equal("app"))
+		Expect(processed[1].Details).To(HaveKeyWithValue("age", "48h, 72h"))
+	})
+
+	It("limit keeps the most severe violations in order", func() {
+		processed := NewLimit(2).Process(violations, nil)
+		Expect(names(processed)).To(Equal([]string{"a/" + validatorA, "b/" + validatorB}))
+
+		Expect(NewLimit(10).Process(violations, nil)).To(HaveLen(4))
+	})
+
+	Describe("roll-up", func() {
+		var resources []unstructured.Unstructured
+
+		newResource := func(kind string, name string, owner *unstructured.Unstructured) unstructured.Unstructured {
+			resource := unstructured.Unstructured{}
+			resource.SetAPIVersion("apps/v1")
+			resource.SetKind(kind)
+			resource.SetName(name)
+			resource.SetNamespace("namespace")
+			resource.SetUID(types.UID(kind + "-" + name))
+			if owner != nil {
+				isController := true
+				resource.SetOwnerReferences([]metav1.OwnerReference{{
+					APIVersion: owner.GetAPIVersion(),
+					Kind:       owner.GetKind(),
+					Name:       owner.GetName(),
+					UID:        owner.GetUID(),
+					Controller: &isController,
+				}})
+			}
+			return resource
+		}
+
+		podViolation := func(pod unstructured.Unstructured, validatorName string, severity common.Severity) common.Violation {
+			return common.NewViolation(pod, "Pod is stale", severity.Level(), validatorName).WithRuleID("stale-pod")
+		}
+
+		BeforeEach(func() {
+			deployment := newResource(common.KIND_DEPLOYMENT, "deployment", nil)
+			replicaSet := newResource(common.KIND_REPLICA_SET, "deployment-1", &deployment)
+			missingReplicaSet := newResource(common.KIND_REPLICA_SET, "other-1", nil)
+			resources = []unstructured.Unstructured{
+				deployment,
+				replicaSet,
+				newResource(common.KIND_POD, "deployment-1-b", &replicaSet),
+				newResource(common.KIND_POD, "deployment-1-a", &replicaSet),
+				newResource(common.KIND_POD, "other-1-a", &missingReplicaSet),
+				newResource(common.KIND_POD, "standalone", nil),
+			}
+		})
+
+		It("reports one violation per workload", func() {
+			violations := []common.Violation{
+				podViolation(resources[2], validatorA, common.SeverityLow),
+				podViolation(resources[3], validatorA, common.SeverityHigh),
+				podViolation(resources[3], validatorB, common.SeverityLow),
+				podViolation(resources[4], validatorA, common.SeverityLow),
+				podViolation(resources[5], validatorA, common.SeverityLow),
+			}
+
+			processed := NewRollUp(Selector{}).Process(violations, resources)
+			Expect(names(processed)).To(Equal([]string{
+				"deployment/" + validatorA,
+				"deployment/" + validatorB,
+				"other-1/" + validatorA, // the owner isn't among the resources
+				"standalone/" + validatorA,
+			}))
+
+			Expect(processed[0].Resource.GetKind()).To(Equal(common.KIND_DEPLOYMENT))
+			Expect(processed[0].Severity()).To(Equal(common.SeverityHigh))
+			Expect(processed[0].RuleID).To(Equal("stale-pod"))
+			Expect(processed[0].Details).To(HaveKeyWithValue("podCount", "2"))
+			Expect(processed[0].Details).To(HaveKeyWithValue("pods", "deployment-1-a, deployment-1-b"))
+			Expect(processed[1].Details).To(HaveKeyWithValue("podCount", "1"))
+			Expect(processed[2].Resource.GetKind()).To(Equal(common.KIND_REPLICA_SET))
+			Expect(processed[3]).To(Equal(violations[4]))
+		})
+
+		It("keeps only the fields that the pods share", func() {
+			violations := []common.Violation{
+				common.NewViolation(resources[2], "Pod is stale (2h0m0s old)", common.SeverityLow.Level(), validatorA).
+					WithFieldPath("metadata.creationTimestamp").WithContainerName("app").WithDetail("age", "2h0m0s").WithDetail("maxAge", "1h0m0s"),
+				common.NewViolation(resources[3], "Pod is stale (3h0m0s old)", common.SeverityLow.Level(), validatorA).
+					WithFieldPath("metadata.creationTimestamp").WithContainerName("app").WithDetail("age", "3h0m0s").WithDetail("maxAge", "1h0m0s"),
+			}
+
+			processed := NewRollUp(Selector{}).Process(violations, resources)
+			Expect(processed).To(HaveLen(1))
+			Expect(processed[0].Message).To(Equal(common.NormalizeMessage(violations[0].Message)))
+			Expect(processed[0].FieldPath).To(BeEmpty())
+			Expect(processed[0].ContainerName).To(Equal("app"))
+			Expect(processed[0].Details).To(Equal(map[string]string{"maxAge": "1h0m0s", "podCount": "2", "pods": "deployment-1-a, deployment-1-b"}))
+			Expect(violations[0].Details).To(HaveKeyWithValue("age", "2h0m0s")) // the pods' violations aren't changed
+		})
+
+		It("the fingerprint doesn't depend on the affected pods", func() {
+			first := NewRollUp(Selector{}).Process([]common.Violation{podViolation(resources[2], validatorA, common.SeverityLow)}, resources)
+			second := NewRollUp(Selector{}).Process([]common.Violation{podViolation(resources[3], validatorA, common.SeverityLow)}, resources)
+			Expect(first[0].Fingerprint()).To(Equal(second[0].Fingerprint()))
+		})
+
+		It("only selected violations", func() {
+			violations := []common.Violation{podViolation(resources[2], validatorA, common.SeverityLow)}
+			processed := NewRollUp(Selector{Validators: []string{validatorB}}).Process(violations, resources)
+			Expect(processed).To(Equal(violations))
+		})
+
+		It("stops at ownership cycles", func() {
+			first := newResource(common.KIND_REPLICA_SET, "first", nil)
+			second := newResource(common.KIND_REPLICA_SET, "second", &first)
+			first = newResource(common.KIND_REPLICA_SET, "first", &second)
+			pod := newResource(common.KIND_POD, "pod", &first)
+
+			processed := NewRollUp(Selector{}).Process([]common.Violation{podViolation(pod, validatorA, common.SeverityLow)}, []unstructured.Unstructured{first, second, pod})
+			Expect(processed).To(HaveLen(1))
+			Expect(processed[0].Resource.GetKind()).To(Equal(common.KIND_REPLICA_SET))
+		})
+	})
+
+	It("from configuration", func() {
+		postProcessors, err := NewPostProcessors([]Config{
+			{Type: FilterName, Match: Selector{Severities: []common.Severity{"Info"}}},
+			{Type: EscalationName, MinViolationsPerResource: 2, Severity: "critical"},
+			{Type: MergeName},
+			{Type: LimitName, Max: 1},
+		})
+		Expect(err).To(Succeed())
+
+		processed := Apply(postProcessors, violations, nil)
+		Expect(processed).To(HaveLen(1))
+		Expect(processed[0].Resource.GetName()).To(Equal("b"))
+		Expect(processed[0].Severity()).To(Equal(common.SeverityCritical))
+	})
+
+	DescribeTable("invalid configuration", func(config Config) {
+		_, err := NewPostProcessors([]Config{config})
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("unknown type", Config{Type: "sort"}),
+		Entry("invalid severity", Config{Type: EscalationName, Severity: "urgent"}),
+		Entry("invalid selector severity", Config{Type: FilterName, Match: Selector{Severities: []common.Severity{"urgent"}}}),
+		Entry("no limit", Config{Type: LimitName}),
+	)
+})
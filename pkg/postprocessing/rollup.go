@@ -0,0 +1,187 @@
+package postprocessing
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const RollUpName = "rollup"
+
+/*
+RollUp reports the violations of pods that are owned by a workload (e.g. a Deployment's replicas) once per workload.
+
+The pod's ownerReferences are followed (preferring the controller reference) up to the top-level owner.
+Violations of the same validator and rule with the same (normalized) message are combined into a single violation of the owner,
+whose details list the number of affected pods and their names.
+The rolled-up violation only keeps the message, container name and details that all of its pods' violations share
+(the normalized message, if their messages differ); the field path is dropped, since it refers to the pods rather than to the owner.
+Pods without owners are not changed.
+*/
+type RollUp struct {
+	selector Selector
+	index    *common.ResourceIndex
+}
+
+func NewRollUp(selector Selector) common.PostProcessor {
+	return &RollUp{selector: selector}
+}
+
+func (p *RollUp) GetName() string {
+	return RollUpName
+}
+
+type rollUpKey struct {
+	owner         common.ViolationTarget
+	validatorName string
+	ruleID        string
+	message       string
+}
+
+type rollUpGroup struct {
+	violation common.Violation
+	podNames  []string
+}
+
+func (p *RollUp) Process(violations []common.Violation, resources []unstructured.Unstructured) []common.Violation {
+	// the index only belongs to the resources of this call
+	index := p.index
+	p.index = nil
+	if index == nil {
+		index = common.NewResourceIndex(resources)
+	}
+
+	var order []rollUpKey
+	groups := make(map[rollUpKey]*rollUpGroup)
+	response := make([]common.Violation, 0, len(violations))
+	positions := make(map[rollUpKey]int) // position of the rolled-up violation in response
+
+	for _, violation := range violations {
+		if violation.Resource == nil || violation.Resource.GetKind() != common.KIND_POD || !p.selector.Matches(violation) {
+			response = append(response, violation)
+			continue
+		}
+
+		owner := topLevelOwner(index, violation.Resource)
+		if owner == nil {
+			response = append(response, violation)
+			continue
+		}
+
+		key := rollUpKey{
+			owner:         common.NewViolationTarget(owner),
+			validatorName: violation.ValidatorName,
+			ruleID:        violation.RuleID,
+			message:       common.NormalizeMessage(violation.Message),
+		}
+
+		group, found := groups[key]
+		if !found {
+			group = &rollUpGroup{violation: violation}
+			group.violation.Resource = owner
+			group.violation.FieldPath = ""
+			group.violation.Details = maps.Clone(violation.Details)
+			groups[key] = group
+			order = append(order, key)
+			positions[key] = len(response)
+			response = append(response, common.Violation{}) // placeholder
+		} else {
+			group.keepSharedFields(violation)
+		}
+
+		group.violation.Level = min(group.violation.Level, violation.Level)
+		if podName := violation.Resource.GetName(); !slices.Contains(group.podNames, podName) {
+			group.podNames = append(group.podNames, podName)
+		}
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		slices.Sort(group.podNames)
+		response[positions[key]] = group.violation.
+			WithDetail("podCount", strconv.Itoa(len(group.podNames))).
+			WithDetail("pods", strings.Join(group.podNames, ", "))
+	}
+
+	return response
+}
+
+// keepSharedFields clears the pod-specific fields of the rolled-up violation, in which violation differs from it
+func (g *rollUpGroup) keepSharedFields(violation common.Violation) {
+	if g.violation.Message != violation.Message {
+		g.violation.Message = common.NormalizeMessage(violation.Message)
+	}
+	if g.violation.ContainerName != violation.ContainerName {
+		g.violation.ContainerName = ""
+	}
+	maps.DeleteFunc(g.violation.Details, func(key string, value string) bool {
+		other, found := violation.Details[key]
+		return !found || other != value
+	})
+}
+
+/*
+SetResourceIndex sets the index of the resources passed to the next call of Process(), which clears it.
+If it isn't set, an index is built by Process().
+*/
+func (p *RollUp) SetResourceIndex(index *common.ResourceIndex) {
+	p.index = index
+}
+
+/*
+topLevelOwner follows the resource's ownerReferences, and returns its top-level owner (nil if it has no owners).
+An owner that isn't among the resources ends the walk; it is returned as a resource that only has the owner reference's identity.
+*/
+func topLevelOwner(index *common.ResourceIndex, resource *unstructured.Unstructured) *unstructured.Unstructured {
+	var owner *unstructured.Unstructured
+	visited := map[*unstructured.Unstructured]bool{}
+	current := resource
+
+	for {
+		reference := ownerReference(current.GetOwnerReferences())
+		if reference == nil {
+			return owner
+		}
+
+		next, found := index.Owner(current, *reference)
+		if !found {
+			return ownerFromReference(*reference, current.GetNamespace())
+		}
+		if visited[next] {
+			return owner // ownership cycle
+		}
+		visited[next] = true
+
+		owner = next
+		current = next
+	}
+}
+
+// ownerReference returns the controller reference, or the first reference if there is no controller
+func ownerReference(references []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range references {
+		if references[i].Controller != nil && *references[i].Controller {
+			return &references[i]
+		}
+	}
+	if len(references) > 0 {
+		return &references[0]
+	}
+	return nil
+}
+
+func ownerFromReference(reference metav1.OwnerReference, namespace string) *unstructured.Unstructured {
+	response := &unstructured.Unstructured{}
+	response.SetAPIVersion(reference.APIVersion)
+	response.SetKind(reference.Kind)
+	response.SetName(reference.Name)
+	response.SetNamespace(namespace)
+	response.SetUID(reference.UID)
+	return response
+}
@@ -0,0 +1,261 @@
+/*
+Package reload watches the files of long-running deployments (e.g. mounted ConfigMaps), and reloads the components that read them
+when their content changes.
+
+Components implement common.Reloadable: they validate the new content before replacing the current content, so that an invalid
+update leaves the previous content in use. Each reload is logged, and counted in Stats.
+*/
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/spf13/afero"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultDebounce     = time.Second
+)
+
+type Options struct {
+	/*
+		PollInterval is the interval, at which the files are compared to their previous content,
+		unless they are on the operating system's file system, where changes are watched; defaults to 10s
+	*/
+	PollInterval time.Duration
+	/*
+		Debounce is the delay between a file system event and the reload, so that the events of a single update
+		(e.g. the symlink swaps of a ConfigMap volume) cause a single reload; defaults to 1s
+	*/
+	Debounce time.Duration
+}
+
+/*
+Stats counts the reloads of a Watcher, e.g. for exposing them as metrics
+*/
+type Stats struct {
+	Successes   int64      `json:"successes"`
+	Failures    int64      `json:"failures"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	LastError   string     `json:"lastError,omitempty"` // of the last failure
+}
+
+type Watcher struct {
+	appFs     afero.Fs
+	targets   []common.Reloadable
+	options   Options
+	checksums map[string]string // by path; empty if the file doesn't exist
+	stats     Stats
+	mutex     sync.Mutex // guards checksums and stats
+	logger    logr.Logger
+}
+
+/*
+Reloadables returns the validators that implement common.Reloadable
+*/
+func Reloadables(validators []common.Validator) []common.Reloadable {
+	var response []common.Reloadable
+	for _, validator := range validators {
+		if reloadable, ok := validator.(common.Reloadable); ok {
+			response = append(response, reloadable)
+		}
+	}
+	return response
+}
+
+/*
+NewWatcher watches the files of targets, e.g. of a validation.Validation and of reload.Reloadables(validators).
+The current content of the files is considered loaded. Targets that implement common.Watchable stop reading their files on each run,
+and rely on the watcher instead, so it must be run (or checked) from then on.
+*/
+func NewWatcher(ctx context.Context, targets []common.Reloadable, options Options) (*Watcher, error) {
+	if options.PollInterval == 0 {
+		options.PollInterval = defaultPollInterval
+	}
+	if options.Debounce == 0 {
+		options.Debounce = defaultDebounce
+	}
+
+	response := Watcher{
+		appFs:     ctx.Value(common.FileSystemContextKey).(afero.Fs),
+		targets:   targets,
+		options:   options,
+		checksums: map[string]string{},
+	}
+
+	var err error
+	response.logger, err = logr.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		if watchable, ok := target.(common.Watchable); ok {
+			watchable.SetWatched(true)
+		}
+		for _, path := range target.WatchedFiles() {
+			response.checksums[path], err = response.checksum(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &response, nil
+}
+
+/*
+Stats returns the reload counters
+*/
+func (w *Watcher) Stats() Stats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.stats
+}
+
+/*
+Run blocks until ctx is done, reloading targets when their files change.
+Files on the operating system's file system are watched; others (e.g. of an in-memory file system) are polled.
+*/
+func (w *Watcher) Run(ctx context.Context) error {
+	if _, isOsFs := w.appFs.(*afero.OsFs); isOsFs {
+		err := w.watch(ctx)
+		if err == nil {
+			return nil
+		}
+		w.logger.Error(err, "couldn't watch configuration files; polling them instead")
+	}
+
+	ticker := time.NewTicker(w.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+/*
+Check reloads the targets whose files changed since they were last loaded
+*/
+func (w *Watcher) Check() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, target := range w.targets {
+		changed := map[string]string{}
+		for _, path := range target.WatchedFiles() {
+			checksum, err := w.checksum(path)
+			if err != nil {
+				w.logger.Error(err, "couldn't read configuration file", "path", path)
+				continue
+			}
+			if checksum != w.checksums[path] {
+				changed[path] = checksum
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		// an invalid update is reported once, rather than on each check, until the files change again
+		for path, checksum := range changed {
+			w.checksums[path] = checksum
+		}
+
+		now := time.Now()
+		if err := target.Reload(); err != nil {
+			w.stats.Failures++
+			w.stats.LastFailure = &now
+			w.stats.LastError = err.Error()
+			w.logger.Error(err, fmt.Sprintf("couldn't reload %s; the previous content remains in use", describe(target)))
+			continue
+		}
+		w.stats.Successes++
+		w.stats.LastSuccess = &now
+		w.logger.V(0).Info(fmt.Sprintf("reloaded %s", describe(target)))
+	}
+}
+
+/*
+watch reloads targets on file system events in the directories of their files,
+since e.g. ConfigMap volumes are updated by replacing a symlink, rather than by writing the files.
+*/
+func (w *Watcher) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	directories := map[string]bool{}
+	for _, target := range w.targets {
+		for _, path := range target.WatchedFiles() {
+			directories[filepath.Dir(path)] = true
+		}
+	}
+	for directory := range directories {
+		if err = watcher.Add(directory); err != nil {
+			return fmt.Errorf("couldn't watch %s: %w", directory, err)
+		}
+	}
+
+	debounce := time.NewTimer(w.options.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("file system events closed")
+			}
+			debounce.Reset(w.options.Debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("file system events closed")
+			}
+			w.logger.Error(err, "error watching configuration files")
+		case <-debounce.C:
+			w.Check()
+		}
+	}
+}
+
+func (w *Watcher) checksum(path string) (string, error) {
+	content, err := afero.ReadFile(w.appFs, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func describe(target common.Reloadable) string {
+	if validator, ok := target.(common.Validator); ok {
+		return validator.GetName()
+	}
+	return strings.Join(target.WatchedFiles(), ", ")
+}
@@ -0,0 +1,153 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	"github.com/tonglil/buflogr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+	"github.com/SAP/k8s-resource-validator/pkg/validators/allowed_pods"
+	"github.com/SAP/k8s-resource-validator/pkg/validators/freshness"
+)
+
+const configDirectory = "/config"
+
+var (
+	ctx       context.Context
+	appFs     afero.Fs
+	logBuffer bytes.Buffer
+	pod       unstructured.Unstructured
+)
+
+func TestReload(t *testing.T) {
+	RegisterFailHandler(Fail)
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	reporterConfig.JUnitReport = "tests.xml"
+	RunSpecs(t, "Reload Test Suite", suiteConfig, reporterConfig)
+}
+
+var _ = Describe("Watcher", func() {
+	BeforeEach(func() {
+		os.Setenv("CONFIG_DIR", configDirectory)
+		DeferCleanup(os.Unsetenv, "CONFIG_DIR")
+
+		appFs = afero.NewMemMapFs()
+		logBuffer.Reset()
+		ctx = logr.NewContext(context.Background(), buflogr.NewWithBuffer(&logBuffer))
+		ctx = context.WithValue(ctx, common.FileSystemContextKey, appFs)
+
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		writeFile("allowlist.yaml", "- name: allowed\n  namespace: default\n  kind: Pod\n")
+
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.Pod{})
+		Expect(err).To(Succeed())
+		pod = unstructured.Unstructured{Object: object}
+		pod.SetKind(common.KIND_POD)
+		pod.SetNamespace("default")
+		pod.SetName("allowed")
+	})
+
+	It("reloads validators when their files change, unless the new content is invalid", func() {
+		validator, err := allowed_pods.NewAllowedPodsValidator(ctx, configDirectory)
+		Expect(err).To(Succeed())
+		freshnessValidator, err := freshness.NewFreshnessValidator(ctx, 1)
+		Expect(err).To(Succeed())
+		reloadables := Reloadables([]common.Validator{validator, freshnessValidator})
+		Expect(reloadables).To(HaveLen(1))
+
+		watcher, err := NewWatcher(ctx, reloadables, Options{})
+		Expect(err).To(Succeed())
+		Expect(validate(validator)).To(BeEmpty())
+
+		watcher.Check()
+		Expect(watcher.Stats().Successes).To(BeZero()) // unchanged
+
+		writeFile("allowlist.yaml", "- name: other\n  namespace: default\n  kind: Pod\n")
+		watcher.Check()
+		Expect(watcher.Stats().Successes).To(Equal(int64(1)))
+		Expect(watcher.Stats().LastSuccess).NotTo(BeNil())
+		Expect(logBuffer.String()).To(ContainSubstring("reloaded " + allowed_pods.ValidatorName))
+		Expect(validate(validator)).To(HaveLen(1))
+
+		writeFile("allowlist.yaml", "- {name: '/[/', namespace: default, kind: Pod}\n")
+		watcher.Check()
+		watcher.Check() // reported once
+		stats := watcher.Stats()
+		Expect(stats.Successes).To(Equal(int64(1)))
+		Expect(stats.Failures).To(Equal(int64(1)))
+		Expect(stats.LastError).To(ContainSubstring("invalid regular expression"))
+		Expect(logBuffer.String()).To(ContainSubstring("the previous content remains in use"))
+		Expect(validate(validator)).To(HaveLen(1)) // the previous allowlist
+	})
+
+	It("reloads the configuration of a validation", func() {
+		writeFile("config.yaml", "freshness:\n  thresholdInHours: 1\n")
+		validationInstance, err := validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		Expect(validationInstance.WatchedFiles()).To(Equal([]string{filepath.Join(configDirectory, "config.yaml")}))
+
+		watcher, err := NewWatcher(ctx, []common.Reloadable{validationInstance}, Options{})
+		Expect(err).To(Succeed())
+
+		writeFile("config.yaml", "freshness:\n  thresholdInHours: 2\n")
+		watcher.Check()
+		Expect(validationInstance.GetConfig().Freshness.ThresholdInHours).To(Equal(int32(2)))
+
+		writeFile("config.yaml", "severities:\n  - validator: built-in:freshness\n    severity: unknown\n")
+		watcher.Check()
+		Expect(watcher.Stats().Failures).To(Equal(int64(1)))
+		Expect(validationInstance.GetConfig().Freshness.ThresholdInHours).To(Equal(int32(2)))
+
+		writeFile("config.yaml", "freshness: [\n")
+		watcher.Check()
+		Expect(watcher.Stats().Failures).To(Equal(int64(2)))
+		Expect(validationInstance.GetConfig().Freshness.ThresholdInHours).To(Equal(int32(2)))
+	})
+
+	It("watches the operating system's file system", func() {
+		directory := GinkgoT().TempDir()
+		path := filepath.Join(directory, "allowlist.yaml")
+		Expect(os.WriteFile(path, []byte("- name: allowed\n  namespace: default\n  kind: Pod\n"), 0644)).To(Succeed())
+
+		ctx = context.WithValue(ctx, common.FileSystemContextKey, afero.NewOsFs())
+		validator, err := allowed_pods.NewAllowedPodsValidator(ctx, directory)
+		Expect(err).To(Succeed())
+		watcher, err := NewWatcher(ctx, Reloadables([]common.Validator{validator}), Options{Debounce: 10 * time.Millisecond, PollInterval: time.Hour})
+		Expect(err).To(Succeed())
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(watcher.Run(runCtx)).To(Succeed())
+		}()
+
+		Eventually(func() int64 {
+			_ = os.WriteFile(path, []byte("- name: other\n  namespace: default\n  kind: Pod\n"), 0644)
+			return watcher.Stats().Successes
+		}).Should(Equal(int64(1)))
+	})
+})
+
+func writeFile(name string, content string) {
+	Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, name), []byte(content), 0644)).To(Succeed())
+}
+
+func validate(validator common.Validator) []common.Violation {
+	violations, err := validator.Validate([]unstructured.Unstructured{pod})
+	Expect(err).To(Succeed())
+	return violations
+}
@@ -0,0 +1,287 @@
+/*
+Package server exposes a Validation instance over HTTP, so that other tools can trigger validation runs on demand.
+
+The server is embeddable: use Handler() to mount the endpoints on an existing mux, or ListenAndServe() to run it standalone.
+*/
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/reload"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+)
+
+const (
+	shutdownTimeout  = 10 * time.Second
+	maxRequestBytes  = 1 << 20
+	metricPrefix     = "k8s_resource_validator_"
+	readinessTimeout = 5 * time.Second
+)
+
+/*
+RunRequest is the (optional) body of a POST /v1/runs request.
+Empty fields mean "all namespaces" and "all registered validators" respectively.
+*/
+type RunRequest struct {
+	Namespaces []string `json:"namespaces,omitempty"`
+	Validators []string `json:"validators,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type Server struct {
+	validation *validation.Validation
+	validators []common.Validator
+	history    *validation.ResultHistory
+	watcher    *reload.Watcher
+	ctx        context.Context
+	logger     logr.Logger
+}
+
+func NewServer(ctx context.Context, validationInstance *validation.Validation, validators []common.Validator) (*Server, error) {
+	if validationInstance == nil {
+		return nil, errors.New("validation instance is nil")
+	}
+
+	response := Server{
+		validation: validationInstance,
+		validators: validators,
+		history:    validation.NewResultHistory(1),
+		ctx:        ctx,
+	}
+
+	var err error
+	response.logger, err = logr.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+/*
+SetResultHistory replaces the server's history, e.g. in order to share it with a daemon.Daemon
+*/
+func (s *Server) SetResultHistory(history *validation.ResultHistory) {
+	s.history = history
+}
+
+/*
+SetReloadWatcher sets the watcher of the configuration files, whose reload counters are served by GET /v1/reloads
+*/
+func (s *Server) SetReloadWatcher(watcher *reload.Watcher) {
+	s.watcher = watcher
+}
+
+/*
+Handler returns the HTTP handler serving the following endpoints:
+
+	POST /v1/runs       - perform a validation run and return its result
+	GET  /v1/runs       - return the retained results, oldest first
+	GET  /v1/runs/last  - return the result of the most recent run
+	GET  /v1/outcomes   - return the number of runs by outcome (Completed, Aborted or Failed)
+	GET  /v1/validators - list the names of the registered validators
+	GET  /v1/reloads    - return the reload counters of the configuration files (see SetReloadWatcher())
+	GET  /metrics       - return the run and reload counters in the Prometheus text format
+	GET  /healthz       - liveness probe
+	GET  /readyz        - readiness probe (the cluster is reachable)
+*/
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/runs", s.handleRun)
+	mux.HandleFunc("GET /v1/runs", s.handleRuns)
+	mux.HandleFunc("GET /v1/runs/last", s.handleLastRun)
+	mux.HandleFunc("GET /v1/outcomes", s.handleOutcomes)
+	mux.HandleFunc("GET /v1/validators", s.handleValidators)
+	mux.HandleFunc("GET /v1/reloads", s.handleReloads)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /healthz", s.handleLiveness)
+	mux.HandleFunc("GET /readyz", s.handleReadiness)
+	return mux
+}
+
+/*
+ListenAndServe serves Handler() on addr until ctx is done
+*/
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		s.logger.V(1).Info(fmt.Sprintf("listening on %s", addr))
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+/*
+Run performs a validation run on freshly fetched resources, scoped to the given namespaces and validators (all if empty), and records its result
+*/
+func (s *Server) Run(request RunRequest) (*validation.Result, error) {
+	validators, err := s.selectValidators(request.Validators)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.validation.RefreshAndValidateWithResult(validators, request.Namespaces)
+	s.history.Add(result)
+
+	return result, nil
+}
+
+/*
+LastResult returns the result of the most recent run, or nil if no run was performed yet
+*/
+func (s *Server) LastResult() *validation.Result {
+	return s.history.Last()
+}
+
+func (s *Server) selectValidators(names []string) ([]common.Validator, error) {
+	if len(names) == 0 {
+		return s.validators, nil
+	}
+
+	var response []common.Validator
+	for _, name := range names {
+		idx := slices.IndexFunc(s.validators, func(validator common.Validator) bool {
+			return validator.GetName() == name
+		})
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown validator: %s", name)
+		}
+		response = append(response, s.validators[idx])
+	}
+	return response, nil
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	var request RunRequest
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %s", err)})
+			return
+		}
+	}
+
+	result, err := s.Run(request)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.history.All())
+}
+
+func (s *Server) handleLastRun(w http.ResponseWriter, r *http.Request) {
+	result := s.LastResult()
+	if result == nil {
+		s.writeJSON(w, http.StatusNotFound, errorResponse{Error: "no validation run was performed yet"})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleOutcomes(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.history.Outcomes())
+}
+
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.validators))
+	for _, validator := range s.validators {
+		names = append(names, validator.GetName())
+	}
+
+	s.writeJSON(w, http.StatusOK, names)
+}
+
+func (s *Server) handleReloads(w http.ResponseWriter, r *http.Request) {
+	if s.watcher == nil {
+		s.writeJSON(w, http.StatusNotFound, errorResponse{Error: "configuration files are not watched"})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.watcher.Stats())
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var metrics strings.Builder
+	writeCounter := func(name string, help string, label string, values map[string]int64) {
+		fmt.Fprintf(&metrics, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			fmt.Fprintf(&metrics, "%s{%s=%q} %d\n", name, label, key, values[key])
+		}
+	}
+
+	outcomes := make(map[string]int64)
+	for outcome, count := range s.history.Outcomes() {
+		outcomes[string(outcome)] = count
+	}
+	writeCounter(metricPrefix+"runs_total", "Validation runs by outcome.", "outcome", outcomes)
+	if s.watcher != nil {
+		stats := s.watcher.Stats()
+		writeCounter(metricPrefix+"reloads_total", "Reloads of configuration files by result.", "result",
+			map[string]int64{"success": stats.Successes, "failure": stats.Failures})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(metrics.String()))
+}
+
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if err := s.validation.Ping(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error(err, "couldn't write response")
+	}
+}
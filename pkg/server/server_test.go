@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/testr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	testclient "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/reload"
+	"github.com/SAP/k8s-resource-validator/pkg/validation"
+	"github.com/SAP/k8s-resource-validator/pkg/validators/fake"
+	"github.com/SAP/k8s-resource-validator/pkg/validators/freshness"
+)
+
+var (
+	ctx        context.Context
+	appFs      afero.Fs
+	logger     logr.Logger
+	testServer *httptest.Server
+)
+
+func TestServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	suiteConfig, reporterConfig := GinkgoConfiguration()
+	reporterConfig.JUnitReport = "tests.xml"
+	RunSpecs(t, "Server Test Suite", suiteConfig, reporterConfig)
+}
+
+var _ = Describe("Server", func() {
+	BeforeEach(func() {
+		os.Unsetenv("CONFIG_DIR")
+		ctx = context.Background()
+		appFs = afero.NewMemMapFs()
+		logger = testr.New(&testing.T{})
+		ctx = logr.NewContext(ctx, logger)
+		ctx = context.WithValue(ctx, common.FileSystemContextKey, appFs)
+
+		validationInstance, err := validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		scheme := runtime.NewScheme()
+		_ = appsv1.AddToScheme(scheme)
+		_ = corev1.AddToScheme(scheme)
+		_ = batchv1.AddToScheme(scheme)
+
+		validationInstance.SetClient(&validation.K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+
+		fakeValidator, err := fake.NewFakeValidator(ctx, 2, false)
+		Expect(err).To(Succeed())
+
+		server, err := NewServer(ctx, validationInstance, []common.Validator{fakeValidator})
+		Expect(err).To(Succeed())
+
+		testServer = httptest.NewServer(server.Handler())
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("list validators", func() {
+		response, err := http.Get(testServer.URL + "/v1/validators")
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		var names []string
+		Expect(json.NewDecoder(response.Body).Decode(&names)).To(Succeed())
+		Expect(names).To(Equal([]string{fake.ValidatorName}))
+	})
+
+	It("no last run", func() {
+		response, err := http.Get(testServer.URL + "/v1/runs/last")
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+	})
+
+	It("run and fetch last result", func() {
+		response, err := http.Post(testServer.URL+"/v1/runs", "application/json", nil)
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		var result validation.Result
+		Expect(json.NewDecoder(response.Body).Decode(&result)).To(Succeed())
+		Expect(result.Outcome).To(Equal(validation.OutcomeCompleted))
+		Expect(result.Violations).To(HaveLen(2))
+		Expect(result.Validators).To(Equal([]string{fake.ValidatorName}))
+
+		lastResponse, err := http.Get(testServer.URL + "/v1/runs/last")
+		Expect(err).To(Succeed())
+		defer lastResponse.Body.Close()
+		Expect(lastResponse.StatusCode).To(Equal(http.StatusOK))
+
+		var lastResult validation.Result
+		Expect(json.NewDecoder(lastResponse.Body).Decode(&lastResult)).To(Succeed())
+		Expect(lastResult.Violations).To(HaveLen(2))
+
+		outcomesResponse, err := http.Get(testServer.URL + "/v1/outcomes")
+		Expect(err).To(Succeed())
+		defer outcomesResponse.Body.Close()
+		var outcomes map[validation.Outcome]int64
+		Expect(json.NewDecoder(outcomesResponse.Body).Decode(&outcomes)).To(Succeed())
+		Expect(outcomes).To(Equal(map[validation.Outcome]int64{validation.OutcomeCompleted: 1, validation.OutcomeAborted: 0, validation.OutcomeFailed: 0}))
+	})
+
+	It("each run fetches the resources anew", func() {
+		scheme := runtime.NewScheme()
+		_ = appsv1.AddToScheme(scheme)
+		_ = corev1.AddToScheme(scheme)
+		_ = batchv1.AddToScheme(scheme)
+		dynamicClient := testclient.NewSimpleDynamicClient(scheme)
+		validationInstance, err := validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validationInstance.SetClient(&validation.K8SProvider{Dynamic: dynamicClient, ClientSet: k8sfake.NewSimpleClientset()})
+		freshnessValidator, err := freshness.NewFreshnessValidator(ctx, 1)
+		Expect(err).To(Succeed())
+		server, err := NewServer(ctx, validationInstance, []common.Validator{freshnessValidator})
+		Expect(err).To(Succeed())
+		freshServer := httptest.NewServer(server.Handler())
+		defer freshServer.Close()
+
+		run := func() validation.Result {
+			response, err := http.Post(freshServer.URL+"/v1/runs", "application/json", nil)
+			Expect(err).To(Succeed())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			var result validation.Result
+			Expect(json.NewDecoder(response.Body).Decode(&result)).To(Succeed())
+			return result
+		}
+		Expect(run().Violations).To(BeEmpty())
+
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stale", CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour))},
+		})
+		Expect(err).To(Succeed())
+		_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("default").
+			Create(ctx, &unstructured.Unstructured{Object: object}, metav1.CreateOptions{})
+		Expect(err).To(Succeed())
+
+		result := run()
+		Expect(result.Violations).To(HaveLen(1))
+		Expect(result.Violations[0].Resource.Name).To(Equal("stale"))
+	})
+
+	It("run scoped to namespaces", func() {
+		body := strings.NewReader(`{"namespaces": ["other"]}`)
+		response, err := http.Post(testServer.URL+"/v1/runs", "application/json", body)
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		var result validation.Result
+		Expect(json.NewDecoder(response.Body).Decode(&result)).To(Succeed())
+		Expect(result.Violations).To(BeEmpty())
+		Expect(result.Namespaces).To(Equal([]string{"other"}))
+	})
+
+	It("run with unknown validator", func() {
+		body := strings.NewReader(`{"validators": ["does-not-exist"]}`)
+		response, err := http.Post(testServer.URL+"/v1/runs", "application/json", body)
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("reload counters", func() {
+		response, err := http.Get(testServer.URL + "/v1/reloads")
+		Expect(err).To(Succeed())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+
+		validationInstance, err := validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		watcher, err := reload.NewWatcher(ctx, []common.Reloadable{validationInstance}, reload.Options{})
+		Expect(err).To(Succeed())
+		watchingServer, err := NewServer(ctx, validationInstance, nil)
+		Expect(err).To(Succeed())
+		watchingServer.SetReloadWatcher(watcher)
+		watchingTestServer := httptest.NewServer(watchingServer.Handler())
+		defer watchingTestServer.Close()
+
+		response, err = http.Get(watchingTestServer.URL + "/v1/reloads")
+		Expect(err).To(Succeed())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		var stats reload.Stats
+		Expect(json.NewDecoder(response.Body).Decode(&stats)).To(Succeed())
+		Expect(stats).To(Equal(reload.Stats{}))
+
+		metrics, err := http.Get(watchingTestServer.URL + "/metrics")
+		Expect(err).To(Succeed())
+		defer metrics.Body.Close()
+		content, err := io.ReadAll(metrics.Body)
+		Expect(err).To(Succeed())
+		Expect(string(content)).To(ContainSubstring("# TYPE k8s_resource_validator_reloads_total counter\n"))
+		Expect(string(content)).To(ContainSubstring(`k8s_resource_validator_reloads_total{result="failure"} 0`))
+		Expect(string(content)).To(ContainSubstring(`k8s_resource_validator_runs_total{outcome="Completed"} 0`))
+	})
+
+	It("liveness and readiness", func() {
+		response, err := http.Get(testServer.URL + "/healthz")
+		Expect(err).To(Succeed())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		response, err = http.Get(testServer.URL + "/readyz")
+		Expect(err).To(Succeed())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("not ready while the API server doesn't respond", func() {
+		validationInstance, err := validation.NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validationInstance.SetClient(&validation.K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(runtime.NewScheme()),
+			ClientSet: unreachableClientset{k8sfake.NewSimpleClientset()},
+		})
+		server, err := NewServer(ctx, validationInstance, nil)
+		Expect(err).To(Succeed())
+		unreachableServer := httptest.NewServer(server.Handler())
+		defer unreachableServer.Close()
+
+		response, err := http.Get(unreachableServer.URL + "/readyz")
+		Expect(err).To(Succeed())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	})
+})
+
+// unreachableClientset fails to discover the version of the API server
+type unreachableClientset struct {
+	*k8sfake.Clientset
+}
+
+func (c unreachableClientset) Discovery() discovery.DiscoveryInterface {
+	return unreachableDiscovery{c.Clientset.Discovery().(*fakediscovery.FakeDiscovery)}
+}
+
+type unreachableDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d unreachableDiscovery) ServerVersion() (*version.Info, error) {
+	return nil, errors.New("connection refused")
+}
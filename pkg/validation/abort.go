@@ -0,0 +1,334 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	combineAny = "any"
+	combineAll = "all"
+
+	defaultConfigMapFieldValue = "true"
+)
+
+/*
+AbortCondition decides whether a validation run is aborted, e.g. while the cluster's resources are in transition.
+Conditions are evaluated after the resources were fetched.
+See NewAbortConditions() for the built-in conditions, AnyOf() and AllOf() for combining them, and AbortConditionFunc for custom conditions.
+*/
+type AbortCondition interface {
+	/*
+		ShouldAbort returns true if the run should be aborted.
+		The message states the reason (e.g. `Deployment.apps/default/app is rolling out`), or why the run is not aborted.
+	*/
+	ShouldAbort(ctx context.Context, state AbortState) (abort bool, message string, err error)
+}
+
+/*
+AbortState is the state of the run, which abort conditions are evaluated against
+*/
+type AbortState struct {
+	Client    *K8SProvider
+	Resources []unstructured.Unstructured // the fetched resources
+	Now       time.Time
+}
+
+/*
+AbortConditionFunc is a custom AbortCondition
+*/
+type AbortConditionFunc func(ctx context.Context, state AbortState) (bool, string, error)
+
+func (f AbortConditionFunc) ShouldAbort(ctx context.Context, state AbortState) (bool, string, error) {
+	return f(ctx, state)
+}
+
+/*
+NewAbortConditions returns the conditions of the abort section of config.yaml, combined as configured.
+If no conditions are configured, it returns the condition of the ConfigMap field ConfigMapField.
+*/
+func NewAbortConditions(config common.AbortConfig) (AbortCondition, error) {
+	if len(config.Conditions) == 0 {
+		return configMapFieldCondition(common.ConfigMapFieldConfig{
+			Namespace: config.ConfigMapNamespace,
+			Name:      config.ConfigMapName,
+			Field:     config.ConfigMapField,
+		}), nil
+	}
+
+	var conditions []AbortCondition
+	for i, conditionConfig := range config.Conditions {
+		condition, err := NewAbortCondition(conditionConfig)
+		if err != nil {
+			return nil, fmt.Errorf("condition %d: %w", i+1, err)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	switch config.Combine {
+	case "", combineAny:
+		return AnyOf(conditions...), nil
+	case combineAll:
+		return AllOf(conditions...), nil
+	}
+	return nil, fmt.Errorf("unknown combine %q: expected %s or %s", config.Combine, combineAny, combineAll)
+}
+
+/*
+NewAbortCondition returns a built-in condition
+*/
+func NewAbortCondition(config common.AbortConditionConfig) (AbortCondition, error) {
+	var conditions []AbortCondition
+	if config.ConfigMap != nil {
+		if config.ConfigMap.Namespace == "" || config.ConfigMap.Name == "" || config.ConfigMap.Field == "" {
+			return nil, errors.New("configMap requires a namespace, name and field")
+		}
+		conditions = append(conditions, configMapFieldCondition(*config.ConfigMap))
+	}
+	if config.NamespaceAnnotation != nil {
+		if config.NamespaceAnnotation.Namespace == "" || config.NamespaceAnnotation.Annotation == "" {
+			return nil, errors.New("namespaceAnnotation requires a namespace and annotation")
+		}
+		conditions = append(conditions, namespaceAnnotationCondition(*config.NamespaceAnnotation))
+	}
+	if config.Lease != nil {
+		if config.Lease.Namespace == "" || config.Lease.Name == "" {
+			return nil, errors.New("lease requires a namespace and name")
+		}
+		conditions = append(conditions, leaseCondition(*config.Lease))
+	}
+	if config.Rollout != nil {
+		kinds := config.Rollout.Kinds
+		if len(kinds) == 0 {
+			kinds = rolloutKinds
+		}
+		for _, kind := range kinds {
+			if !slices.Contains(rolloutKinds, kind) {
+				return nil, fmt.Errorf("rollout of unsupported kind %s", kind)
+			}
+		}
+		conditions = append(conditions, rolloutCondition{kinds: kinds})
+	}
+	if config.MaintenanceWindow != nil {
+		window, err := newMaintenanceWindow(*config.MaintenanceWindow)
+		if err != nil {
+			return nil, fmt.Errorf("maintenanceWindow: %w", err)
+		}
+		conditions = append(conditions, window)
+	}
+
+	if len(conditions) != 1 {
+		return nil, fmt.Errorf("expected exactly one of configMap, namespaceAnnotation, lease, rollout or maintenanceWindow, found %d", len(conditions))
+	}
+	return conditions[0], nil
+}
+
+/*
+AnyOf returns a condition that is met if any of the conditions is met.
+Conditions are evaluated in order, until one is met.
+*/
+func AnyOf(conditions ...AbortCondition) AbortCondition {
+	return AbortConditionFunc(func(ctx context.Context, state AbortState) (bool, string, error) {
+		var messages []string
+		for _, condition := range conditions {
+			abort, message, err := condition.ShouldAbort(ctx, state)
+			if err != nil || abort {
+				return abort, message, err
+			}
+			messages = append(messages, message)
+		}
+		return false, strings.Join(messages, "; "), nil
+	})
+}
+
+/*
+AllOf returns a condition that is met if all of the conditions are met.
+Conditions are evaluated in order, until one is not met.
+*/
+func AllOf(conditions ...AbortCondition) AbortCondition {
+	return AbortConditionFunc(func(ctx context.Context, state AbortState) (bool, string, error) {
+		var messages []string
+		for _, condition := range conditions {
+			abort, message, err := condition.ShouldAbort(ctx, state)
+			if err != nil || !abort {
+				return false, message, err
+			}
+			messages = append(messages, message)
+		}
+		return len(conditions) > 0, strings.Join(messages, "; "), nil
+	})
+}
+
+type configMapFieldCondition common.ConfigMapFieldConfig
+
+func (c configMapFieldCondition) ShouldAbort(ctx context.Context, state AbortState) (bool, string, error) {
+	configMap, err := state.Client.ClientSet.CoreV1().ConfigMaps(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{})
+	if err != nil {
+		// if configMap not present, we perform validation anyway
+		if k8sErrors.IsNotFound(err) {
+			return false, fmt.Sprintf("Abort configMap %s not found: Resuming validation", c.Name), nil
+		}
+		return false, "", err
+	}
+
+	value, ok := configMap.Data[c.Field]
+	if !ok {
+		// if field not present, we perform validation anyway
+		return false, fmt.Sprintf("Field %s not found in abort configMap: Resuming validation", c.Field), nil
+	}
+
+	expected := c.Value
+	if expected == "" {
+		expected = defaultConfigMapFieldValue
+	}
+	if value == expected {
+		return true, fmt.Sprintf("Abort configMap %s set to %q: Aborting validation", c.Name, expected), nil
+	}
+
+	return false, fmt.Sprintf("Abort configMap %s found, but field %s is NOT set to %q: Resuming validation", c.Name, c.Field, expected), nil
+}
+
+type namespaceAnnotationCondition common.NamespaceAnnotationConfig
+
+func (c namespaceAnnotationCondition) ShouldAbort(ctx context.Context, state AbortState) (bool, string, error) {
+	namespace, err := state.Client.ClientSet.CoreV1().Namespaces().Get(ctx, c.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return false, fmt.Sprintf("Namespace %s not found: Resuming validation", c.Namespace), nil
+		}
+		return false, "", err
+	}
+
+	value, ok := namespace.Annotations[c.Annotation]
+	if ok && (c.Value == "" || value == c.Value) {
+		return true, fmt.Sprintf("Namespace %s is annotated with %s=%s: Aborting validation", c.Namespace, c.Annotation, value), nil
+	}
+	return false, fmt.Sprintf("Namespace %s is NOT annotated with %s: Resuming validation", c.Namespace, c.Annotation), nil
+}
+
+type leaseCondition common.LeaseConfig
+
+func (c leaseCondition) ShouldAbort(ctx context.Context, state AbortState) (bool, string, error) {
+	lease, err := state.Client.ClientSet.CoordinationV1().Leases(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return false, fmt.Sprintf("Lease %s/%s not found: Resuming validation", c.Namespace, c.Name), nil
+		}
+		return false, "", err
+	}
+
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if expiry.Before(state.Now) {
+			return false, fmt.Sprintf("Lease %s/%s expired at %s: Resuming validation", c.Namespace, c.Name, expiry.Format(time.RFC3339)), nil
+		}
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = fmt.Sprintf(" (held by %s)", *lease.Spec.HolderIdentity)
+	}
+	return true, fmt.Sprintf("Lease %s/%s exists%s: Aborting validation", c.Namespace, c.Name, holder), nil
+}
+
+type rolloutCondition struct {
+	kinds []string
+}
+
+func (c rolloutCondition) ShouldAbort(_ context.Context, state AbortState) (bool, string, error) {
+	rollouts := rolloutDetection{kinds: c.kinds}.detect(state.Resources)
+	if len(rollouts) > 0 {
+		return true, describeRollouts(rollouts) + ": Aborting validation", nil
+	}
+	return false, fmt.Sprintf("No %s is rolling out: Resuming validation", strings.Join(c.kinds, " or ")), nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+type maintenanceWindow struct {
+	config     common.MaintenanceWindowConfig
+	days       map[time.Weekday]bool // nil for every day
+	start, end time.Duration         // since midnight
+	location   *time.Location
+}
+
+func newMaintenanceWindow(config common.MaintenanceWindowConfig) (maintenanceWindow, error) {
+	response := maintenanceWindow{config: config, location: time.UTC}
+
+	for _, day := range config.Days {
+		weekday, found := weekdays[strings.ToLower(day)]
+		if !found {
+			return response, fmt.Errorf("unknown day %q: expected one of mon, tue, wed, thu, fri, sat or sun", day)
+		}
+		if response.days == nil {
+			response.days = map[time.Weekday]bool{}
+		}
+		response.days[weekday] = true
+	}
+
+	var err error
+	if response.start, err = parseTimeOfDay(config.Start); err != nil {
+		return response, fmt.Errorf("invalid start: %w", err)
+	}
+	if response.end, err = parseTimeOfDay(config.End); err != nil {
+		return response, fmt.Errorf("invalid end: %w", err)
+	}
+	if response.start == response.end {
+		return response, errors.New("start and end are equal")
+	}
+
+	if config.TimeZone != "" {
+		if response.location, err = time.LoadLocation(config.TimeZone); err != nil {
+			return response, fmt.Errorf("invalid timeZone: %w", err)
+		}
+	}
+	return response, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM: %s", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func (w maintenanceWindow) ShouldAbort(_ context.Context, state AbortState) (bool, string, error) {
+	now := state.Now.In(w.location)
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+
+	var within bool
+	if w.start < w.end {
+		within = w.startsOn(now.Weekday()) && sinceMidnight >= w.start && sinceMidnight < w.end
+	} else {
+		// the window ends on the next day
+		yesterday := now.AddDate(0, 0, -1).Weekday()
+		within = (w.startsOn(now.Weekday()) && sinceMidnight >= w.start) || (w.startsOn(yesterday) && sinceMidnight < w.end)
+	}
+
+	description := fmt.Sprintf("maintenance window %s-%s %s", w.config.Start, w.config.End, w.location)
+	if len(w.config.Days) > 0 {
+		description += " on " + strings.Join(w.config.Days, ", ")
+	}
+	if within {
+		return true, fmt.Sprintf("Within %s: Aborting validation", description), nil
+	}
+	return false, fmt.Sprintf("Outside %s: Resuming validation", description), nil
+}
+
+func (w maintenanceWindow) startsOn(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
@@ -0,0 +1,149 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const baselineFile = "baseline.yaml"
+
+/*
+BaselineEntry is a known violation that should not be reported.
+Only the fingerprint is used for matching; the other fields help humans to review the baseline.
+*/
+type BaselineEntry struct {
+	Fingerprint string                 `yaml:"fingerprint"`
+	Validator   string                 `yaml:"validator,omitempty"`
+	Rule        string                 `yaml:"rule,omitempty"`
+	Resource    common.ViolationTarget `yaml:"resource,omitempty"`
+	Message     string                 `yaml:"message,omitempty"`
+	Expires     *time.Time             `yaml:"expires,omitempty"` // optional: the violation is reported again from this time on
+}
+
+/*
+Baseline suppresses known violations, e.g. when adopting a new validator on an existing cluster
+*/
+type Baseline []BaselineEntry
+
+/*
+NewBaseline creates a baseline that suppresses the given violations; expires may be nil.
+The baseline is applied before post-processing, so the violations must not be post-processed (e.g. those of validators, rather than of Validate()).
+*/
+func NewBaseline(violations []common.Violation, expires *time.Time) Baseline {
+	response := make(Baseline, 0, len(violations))
+	for _, violation := range violations {
+		response = append(response, BaselineEntry{
+			Fingerprint: violation.Fingerprint(),
+			Validator:   violation.ValidatorName,
+			Rule:        violation.RuleID,
+			Resource:    common.NewViolationTarget(violation.Resource),
+			Message:     violation.Message,
+			Expires:     expires,
+		})
+	}
+	return response
+}
+
+/*
+NewBaselineFromResult creates a baseline that suppresses the violations of a (possibly saved) result; expires may be nil.
+It suppresses the result's BaselineCandidates, which are set by ValidateWithResult(), and its Violations otherwise (e.g. for NewResult()).
+*/
+func NewBaselineFromResult(result *Result, expires *time.Time) Baseline {
+	violations := result.BaselineCandidates
+	if violations == nil {
+		violations = result.Violations
+	}
+
+	response := make(Baseline, 0, len(violations))
+	for _, violation := range violations {
+		response = append(response, BaselineEntry{
+			Fingerprint: violation.GetFingerprint(),
+			Validator:   violation.Validator,
+			Rule:        violation.Rule,
+			Resource:    violation.Resource,
+			Message:     violation.Message,
+			Expires:     expires,
+		})
+	}
+	return response
+}
+
+/*
+Apply splits violations into those that should be reported and those that are suppressed by non-expired baseline entries
+*/
+func (b Baseline) Apply(violations []common.Violation, now time.Time) (reported []common.Violation, suppressed []common.Violation) {
+	if len(b) == 0 {
+		return violations, nil
+	}
+
+	active := make(map[string]bool, len(b))
+	for _, entry := range b {
+		if entry.Expires == nil || now.Before(*entry.Expires) {
+			active[entry.Fingerprint] = true
+		}
+	}
+
+	for _, violation := range violations {
+		if active[violation.Fingerprint()] {
+			suppressed = append(suppressed, violation)
+		} else {
+			reported = append(reported, violation)
+		}
+	}
+	return reported, suppressed
+}
+
+func WriteBaseline(appFs afero.Fs, path string, baseline Baseline) error {
+	content, err := yaml.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(appFs, path, content, 0644)
+}
+
+func ReadBaseline(appFs afero.Fs, path string) (Baseline, error) {
+	content, err := afero.ReadFile(appFs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline Baseline
+	if err := yaml.Unmarshal(content, &baseline); err != nil {
+		return nil, fmt.Errorf("couldn't parse baseline file %s: %w", path, err)
+	}
+
+	for i, entry := range baseline {
+		if entry.Fingerprint == "" {
+			return nil, fmt.Errorf("baseline file %s: entry %d has no fingerprint", path, i)
+		}
+	}
+	return baseline, nil
+}
+
+/*
+reads the baseline from the configuration directory, if present
+*/
+func (v *Validation) readBaseline(dir string) (Baseline, error) {
+	baselineFullPath := filepath.Join(dir, baselineFile)
+
+	baseline, err := ReadBaseline(v.appFs, baselineFullPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			v.logger.V(2).Info("couldn't find baseline file", "path", baselineFullPath)
+			return nil, nil
+		}
+		v.logger.Error(err, "couldn't read baseline file")
+		return nil, err
+	}
+
+	v.logger.V(2).Info(fmt.Sprintf("read %d baseline entries", len(baseline)))
+	return baseline, nil
+}
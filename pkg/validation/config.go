@@ -0,0 +1,411 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	koanfYaml "github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	/*
+		DefaultConfigEnvPrefix is the prefix of environment variables that set configuration values, e.g. KRV_CONFIG_ABORT_CONFIGMAPNAME.
+		Unlike e.g. K8S_RESOURCE_VALIDATOR_, it doesn't collide with the variables of a Service named after the validator (see serviceLinkVariable).
+	*/
+	DefaultConfigEnvPrefix = "KRV_CONFIG_"
+
+	defaultOrigin = "default"
+	redacted      = "<redacted>"
+)
+
+/*
+ConfigLoader loads the configuration from layers, each of which overrides the values of the previous ones:
+
+ 1. the defaults (see common.NewConfig())
+ 2. Files, in order
+ 3. ConfigMap, then Secret: a key of a ConfigMap or Secret in the cluster, which contains a config.yaml
+ 4. environment variables that start with EnvPrefix, e.g. KRV_CONFIG_FRESHNESS_THRESHOLDINHOURS=48
+ 5. Values, e.g. from --set flags (see RegisterFlags())
+
+Environment variables map onto keys by replacing "_" with "." regardless of case; values are parsed as YAML,
+e.g. KRV_CONFIG_EXEMPT_SELECTORS='["app=agent"]'. The variables that Kubernetes sets for Services (e.g. <PREFIX>SERVICE_HOST) are ignored.
+*/
+type ConfigLoader struct {
+	Files     []string
+	ConfigMap *ConfigMapReference
+	Secret    *ConfigMapReference
+	EnvPrefix string   // if empty, environment variables are not read
+	Values    []string // key=value, e.g. abort.configMapName=deploying
+	/*
+		Strict causes unknown keys, values of the wrong type and missing layers to be errors.
+		Otherwise, they are returned as warnings, and the remaining values are loaded. Syntax errors are always errors.
+	*/
+	Strict bool
+}
+
+/*
+ConfigMapReference identifies the key of a ConfigMap or Secret, which contains a config.yaml
+*/
+type ConfigMapReference struct {
+	Namespace string
+	Name      string
+	Key       string // defaults to config.yaml
+}
+
+/*
+LoadedConfig is the effective configuration, together with the origin of each of its values
+*/
+type LoadedConfig struct {
+	Config   common.Config
+	Origins  map[string]string // by key, e.g. "abort.configMapName": "file /config/config.yaml"
+	Warnings []string
+	values   *koanf.Koanf
+	secrets  map[string]bool // keys whose values originate from a Secret
+}
+
+/*
+DefaultConfigLoader loads config.yaml of the configuration directory (see CONFIG_DIR) and environment variables with the default prefix.
+Strict mode is enabled by setting the CONFIG_STRICT environment variable to "true".
+*/
+func DefaultConfigLoader() ConfigLoader {
+	return ConfigLoader{
+		Files:     []string{filepath.Join(resolveConfigDirectory(), configFileName)},
+		EnvPrefix: DefaultConfigEnvPrefix,
+		Strict:    os.Getenv(strictConfigEnv) == "true",
+	}
+}
+
+/*
+RegisterFlags registers command-line flags that add layers to the loader:
+
+	--config <file>                        (repeatable; replaces the default files)
+	--config-map <namespace>/<name>[/<key>]
+	--config-secret <namespace>/<name>[/<key>]
+	--set <key>=<value>                    (repeatable)
+	--strict-config
+*/
+func (l *ConfigLoader) RegisterFlags(flags *flag.FlagSet) {
+	replaceFiles := true
+	flags.Func("config", "a configuration file; may be repeated, later files take precedence", func(value string) error {
+		if replaceFiles {
+			l.Files, replaceFiles = nil, false
+		}
+		l.Files = append(l.Files, value)
+		return nil
+	})
+	flags.Func("config-map", "a ConfigMap key with configuration: <namespace>/<name>[/<key>]", func(value string) (err error) {
+		l.ConfigMap, err = parseConfigMapReference(value)
+		return err
+	})
+	flags.Func("config-secret", "a Secret key with configuration: <namespace>/<name>[/<key>]", func(value string) (err error) {
+		l.Secret, err = parseConfigMapReference(value)
+		return err
+	})
+	flags.Func("set", "a configuration value: <key>=<value>; may be repeated", func(value string) error {
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("expected <key>=<value>: %s", value)
+		}
+		l.Values = append(l.Values, value)
+		return nil
+	})
+	flags.BoolVar(&l.Strict, "strict-config", l.Strict, "fail on unknown configuration keys and invalid values")
+}
+
+func parseConfigMapReference(value string) (*ConfigMapReference, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("expected <namespace>/<name>[/<key>]: %s", value)
+	}
+	response := &ConfigMapReference{Namespace: parts[0], Name: parts[1], Key: configFileName}
+	if len(parts) == 3 {
+		response.Key = parts[2]
+	}
+	return response, nil
+}
+
+/*
+Load loads and merges the layers.
+client is only used to read the ConfigMap and Secret, and may be nil if there are none.
+*/
+func (l ConfigLoader) Load(ctx context.Context, appFs afero.Fs, client *K8SProvider) (*LoadedConfig, error) {
+	loader := layeredConfig{
+		strict: l.Strict,
+		response: &LoadedConfig{
+			Origins: map[string]string{},
+			values:  koanf.New("."),
+			secrets: map[string]bool{},
+		},
+	}
+
+	defaults, err := yaml.Marshal(common.NewConfig())
+	if err != nil {
+		return nil, err
+	}
+	defaultValues, err := koanfYaml.Parser().Unmarshal(defaults)
+	if err != nil {
+		return nil, err
+	}
+	// unset values (e.g. postProcessors) must remain unset, rather than becoming empty
+	loader.merge(defaultOrigin, withoutEmptyValues(defaultValues), false)
+
+	for _, path := range l.Files {
+		content, err := afero.ReadFile(appFs, path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				loader.problem(fmt.Errorf("couldn't find configuration file %s", path))
+			} else {
+				loader.errs = append(loader.errs, fmt.Errorf("couldn't read %s: %w", path, err))
+			}
+			continue
+		}
+		loader.addDocument("file "+path, content, false)
+	}
+
+	if l.ConfigMap != nil {
+		origin := fmt.Sprintf("configmap %s/%s (%s)", l.ConfigMap.Namespace, l.ConfigMap.Name, l.ConfigMap.Key)
+		configMap, err := client.ClientSet.CoreV1().ConfigMaps(l.ConfigMap.Namespace).Get(ctx, l.ConfigMap.Name, metav1.GetOptions{})
+		if err == nil {
+			content, found := configMap.Data[l.ConfigMap.Key]
+			if found {
+				loader.addDocument(origin, []byte(content), false)
+			} else {
+				loader.problem(fmt.Errorf("%s: key not found", origin))
+			}
+		} else {
+			loader.clusterError(origin, err)
+		}
+	}
+
+	if l.Secret != nil {
+		origin := fmt.Sprintf("secret %s/%s (%s)", l.Secret.Namespace, l.Secret.Name, l.Secret.Key)
+		secret, err := client.ClientSet.CoreV1().Secrets(l.Secret.Namespace).Get(ctx, l.Secret.Name, metav1.GetOptions{})
+		if err == nil {
+			content, found := secret.Data[l.Secret.Key]
+			if found {
+				loader.addDocument(origin, content, true)
+			} else {
+				loader.problem(fmt.Errorf("%s: key not found", origin))
+			}
+		} else {
+			loader.clusterError(origin, err)
+		}
+	}
+
+	if l.EnvPrefix != "" {
+		var names []string
+		for _, variable := range os.Environ() {
+			if name, _, _ := strings.Cut(variable, "="); strings.HasPrefix(name, l.EnvPrefix) && !serviceLinkVariable.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			key := strings.ReplaceAll(strings.TrimPrefix(name, l.EnvPrefix), "_", ".")
+			loader.addValue("environment variable "+name, key, os.Getenv(name))
+		}
+	}
+
+	for _, value := range l.Values {
+		key, value, _ := strings.Cut(value, "=")
+		loader.addValue("flag --set "+key, key, value)
+	}
+
+	if len(loader.errs) > 0 {
+		return nil, errors.Join(loader.errs...)
+	}
+
+	response := loader.response
+	merged, err := yaml.Marshal(response.values.Raw())
+	if err != nil {
+		return nil, err
+	}
+	// problems were reported per layer, so that they refer to their origins; the merged configuration's warnings are redundant
+	response.Config, _, err = common.ParseConfig(merged, false)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+/*
+Print writes the effective configuration, one value per line, with its origin,
+e.g. abort.configMapName: "landscape-state" # default
+Values that originate from a Secret are redacted.
+*/
+func (c *LoadedConfig) Print(w io.Writer) error {
+	for _, key := range c.values.Keys() {
+		value := c.values.Get(key)
+		rendered, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if c.secrets[key] {
+			rendered = []byte(redacted)
+		}
+		if _, err = fmt.Fprintf(w, "%s: %s # %s\n", key, rendered, c.Origins[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+layeredConfig merges the layers of a ConfigLoader
+*/
+type layeredConfig struct {
+	strict   bool
+	response *LoadedConfig
+	errs     []error
+}
+
+var lineNumber = regexp.MustCompile(`^line \d+: `)
+
+// serviceLinkVariable matches the environment variables that Kubernetes sets for each Service, e.g. <SERVICE>_SERVICE_HOST or <SERVICE>_PORT_8080_TCP_ADDR
+var serviceLinkVariable = regexp.MustCompile(`_(SERVICE_HOST|SERVICE_PORT(_[A-Z0-9_]+)?|PORT(_\d+_(TCP|UDP|SCTP)(_ADDR|_PORT|_PROTO)?)?)$`)
+
+/*
+problem is an error in strict mode, and a warning otherwise
+*/
+func (c *layeredConfig) problem(err error) {
+	if c.strict {
+		c.errs = append(c.errs, err)
+	} else {
+		c.response.Warnings = append(c.response.Warnings, err.Error())
+	}
+}
+
+func (c *layeredConfig) clusterError(origin string, err error) {
+	if k8sErrors.IsNotFound(err) {
+		c.problem(fmt.Errorf("%s: not found", origin))
+	} else {
+		c.errs = append(c.errs, fmt.Errorf("%s: %w", origin, err))
+	}
+}
+
+/*
+addDocument adds a YAML document (e.g. a config.yaml file) as a layer.
+Unknown keys and invalid values are reported with their line numbers.
+*/
+func (c *layeredConfig) addDocument(origin string, content []byte, secret bool) {
+	_, warnings, err := common.ParseConfig(content, false)
+	if err != nil {
+		// syntax errors are errors in either mode, since none of the document's values could be read
+		c.errs = append(c.errs, fmt.Errorf("%s: %w", origin, err))
+		return
+	}
+	for _, warning := range warnings {
+		c.problem(fmt.Errorf("%s: %s", origin, warning))
+	}
+
+	values, err := koanfYaml.Parser().Unmarshal(content)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %w", origin, err))
+		return
+	}
+	c.merge(origin, values, secret)
+}
+
+/*
+addValue adds a single value (e.g. of an environment variable) as a layer.
+The key is matched regardless of case, and the value is parsed as YAML.
+*/
+func (c *layeredConfig) addValue(origin string, key string, value string) {
+	resolved, found := common.ResolveConfigKey(key)
+	if !found {
+		c.problem(fmt.Errorf("%s: unknown key %s", origin, key))
+		return
+	}
+
+	var parsed any
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+
+	values := map[string]any{}
+	nested := values
+	segments := strings.Split(resolved, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		child := map[string]any{}
+		nested[segment] = child
+		nested = child
+	}
+	nested[segments[len(segments)-1]] = parsed
+
+	content, err := yaml.Marshal(values)
+	if err == nil {
+		_, _, err = common.ParseConfig(content, true)
+	}
+	if err != nil {
+		// the line numbers refer to the generated document
+		c.problem(fmt.Errorf("%s: %s", origin, lineNumber.ReplaceAllString(err.Error(), "")))
+		return
+	}
+	c.merge(origin, values, false)
+}
+
+func (c *layeredConfig) merge(origin string, values map[string]any, secret bool) {
+	layer := koanf.New(".")
+	if err := layer.Load(mapProvider(values), nil); err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %w", origin, err))
+		return
+	}
+
+	for _, key := range layer.Keys() {
+		c.response.Origins[key] = origin
+		c.response.secrets[key] = secret
+	}
+	if err := c.response.values.Merge(layer); err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%s: %w", origin, err))
+	}
+}
+
+func withoutEmptyValues(values map[string]any) map[string]any {
+	response := map[string]any{}
+	for key, value := range values {
+		switch typed := value.(type) {
+		case nil:
+			continue
+		case map[string]any:
+			if typed = withoutEmptyValues(typed); len(typed) == 0 {
+				continue
+			}
+			value = typed
+		case []any:
+			if len(typed) == 0 {
+				continue
+			}
+		}
+		response[key] = value
+	}
+	return response
+}
+
+/*
+mapProvider is a koanf.Provider of nested values
+*/
+type mapProvider map[string]any
+
+func (p mapProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("mapProvider doesn't support ReadBytes()")
+}
+
+func (p mapProvider) Read() (map[string]any, error) {
+	return p, nil
+}
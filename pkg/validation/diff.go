@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/afero"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+/*
+ReportDiff is the result of comparing the violations of two validation results
+*/
+type ReportDiff struct {
+	Added     []ViolationReport `json:"added"`     // violations that are only in the current result
+	Resolved  []ViolationReport `json:"resolved"`  // violations that are only in the previous result
+	Unchanged []ViolationReport `json:"unchanged"` // violations that are in both results (as reported by the current result)
+}
+
+/*
+GetFingerprint returns the report's fingerprint, computing it for reports that were saved without one
+*/
+func (r ViolationReport) GetFingerprint() string {
+	if r.Fingerprint != "" {
+		return r.Fingerprint
+	}
+	return common.ComputeFingerprint(r.Validator, r.Rule, r.Resource, r.Message)
+}
+
+/*
+DiffReports compares two sets of violation reports by their fingerprints.
+The order of the violations within each set is preserved.
+*/
+func DiffReports(previous []ViolationReport, current []ViolationReport) ReportDiff {
+	added, resolved, unchanged := common.DiffByFingerprint(previous, current, ViolationReport.GetFingerprint)
+
+	// empty rather than null in JSON
+	response := ReportDiff{Added: []ViolationReport{}, Resolved: []ViolationReport{}, Unchanged: []ViolationReport{}}
+	response.Added = append(response.Added, added...)
+	response.Resolved = append(response.Resolved, resolved...)
+	response.Unchanged = append(response.Unchanged, unchanged...)
+	return response
+}
+
+/*
+DiffResults compares the violations of two validation results; a nil result is treated as having no violations
+*/
+func DiffResults(previous *Result, current *Result) ReportDiff {
+	var previousViolations, currentViolations []ViolationReport
+	if previous != nil {
+		previousViolations = previous.Violations
+	}
+	if current != nil {
+		currentViolations = current.Violations
+	}
+	return DiffReports(previousViolations, currentViolations)
+}
+
+/*
+DiffResultFiles compares the violations of two results that were saved as JSON (see WriteResult())
+*/
+func DiffResultFiles(appFs afero.Fs, previousPath string, currentPath string) (ReportDiff, error) {
+	previous, err := ReadResult(appFs, previousPath)
+	if err != nil {
+		return ReportDiff{}, err
+	}
+
+	current, err := ReadResult(appFs, currentPath)
+	if err != nil {
+		return ReportDiff{}, err
+	}
+
+	return DiffResults(previous, current), nil
+}
+
+/*
+WriteResult saves a result as JSON
+*/
+func WriteResult(appFs afero.Fs, path string, result *Result) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(appFs, path, content, 0644)
+}
+
+/*
+ReadResult reads a result that was saved as JSON
+*/
+func ReadResult(appFs afero.Fs, path string) (*Result, error) {
+	content, err := afero.ReadFile(appFs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Result
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("couldn't parse result file %s: %w", path, err)
+	}
+	return &result, nil
+}
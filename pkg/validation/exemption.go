@@ -0,0 +1,285 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	/*
+		ExemptionAnnotation exempts a resource (and the resources it owns, e.g. a Deployment's pods) from validators or rules.
+		Its value is a comma-separated list of validator names (e.g. "built-in:freshness"),
+		validator names and rules (e.g. "built-in:privileged-pods/privileged") or "*" for all validators.
+	*/
+	ExemptionAnnotation = "k8s-resource-validator.sap.com/exempt"
+	// ExemptionJustificationAnnotation explains why the resource is exempt. It is required.
+	ExemptionJustificationAnnotation = "k8s-resource-validator.sap.com/exempt-justification"
+	// ExemptionExpiresAnnotation is an optional RFC 3339 timestamp or date (e.g. "2024-12-31"), from which the exemption no longer applies
+	ExemptionExpiresAnnotation = "k8s-resource-validator.sap.com/exempt-expires"
+
+	// ExemptionsValidatorName is the validator name of violations of the exemption annotations themselves
+	ExemptionsValidatorName = "built-in:exemptions"
+
+	ruleExpiredExemption       = "expired-exemption"
+	ruleMissingJustification   = "missing-justification"
+	ruleInvalidExemptionExpiry = "invalid-expiry"
+	exemptionDocumentationURL  = common.DocumentationURL + "#exemption-annotations"
+)
+
+/*
+selectorExemption is a parsed selector of a common.ExemptionConfig
+*/
+type selectorExemption struct {
+	validatorName string // empty for all validators
+	selector      labels.Selector
+	namespaced    bool // the selector matches the labels of the resource's namespace
+}
+
+func (e selectorExemption) appliesTo(violation common.Violation) bool {
+	return e.validatorName == "" || e.validatorName == violation.ValidatorName
+}
+
+func (e selectorExemption) justification() string {
+	if e.namespaced {
+		return fmt.Sprintf("the namespace matches the exemption selector %q", e.selector.String())
+	}
+	return fmt.Sprintf("the resource matches the exemption selector %q", e.selector.String())
+}
+
+/*
+parseExemptionConfig validates the configuration, and returns its selectors
+*/
+func parseExemptionConfig(c common.ExemptionConfig) ([]selectorExemption, error) {
+	var response []selectorExemption
+	var errs []error
+
+	add := func(validatorName string, selectors []string, namespaced bool) {
+		for _, selector := range selectors {
+			parsed, err := labels.Parse(selector)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid exemption selector %q: %w", selector, err))
+				continue
+			}
+			response = append(response, selectorExemption{validatorName: validatorName, selector: parsed, namespaced: namespaced})
+		}
+	}
+
+	if c.LabelName != "" {
+		response = append(response, selectorExemption{selector: labels.SelectorFromSet(labels.Set{c.LabelName: c.LabelValue})})
+	}
+	add("", c.Selectors, false)
+	add("", c.NamespaceSelectors, true)
+	for i, validatorExemption := range c.Validators {
+		if validatorExemption.Validator == "" {
+			errs = append(errs, fmt.Errorf("exemption %d: a validator name is required", i))
+			continue
+		}
+		add(validatorExemption.Validator, validatorExemption.Selectors, false)
+		add(validatorExemption.Validator, validatorExemption.NamespaceSelectors, true)
+	}
+
+	return response, errors.Join(errs...)
+}
+
+func hasNamespaceSelectors(selectorExemptions []selectorExemption) bool {
+	return slices.ContainsFunc(selectorExemptions, func(e selectorExemption) bool { return e.namespaced })
+}
+
+/*
+fetchNamespaces returns the cluster's namespaces by name, as unstructured resources that only have a name and labels
+*/
+func fetchNamespaces(ctx context.Context, client K8SProvider) (map[string]*unstructured.Unstructured, error) {
+	namespaceList, err := client.ClientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[string]*unstructured.Unstructured, len(namespaceList.Items))
+	for _, namespace := range namespaceList.Items {
+		resource := &unstructured.Unstructured{}
+		resource.SetAPIVersion("v1")
+		resource.SetKind(common.KIND_NAMESPACE)
+		resource.SetName(namespace.Name)
+		resource.SetLabels(namespace.Labels)
+		response[namespace.Name] = resource
+	}
+	return response, nil
+}
+
+/*
+ExemptedViolation is a violation that was not reported, since its resource (or one of the resource's owners) is exempt
+*/
+type ExemptedViolation struct {
+	Violation     common.Violation
+	ExemptedBy    *unstructured.Unstructured // the annotated resource
+	Justification string
+	Expires       *time.Time
+}
+
+type exemption struct {
+	resource      *unstructured.Unstructured
+	scopes        []string
+	justification string
+	expires       *time.Time
+}
+
+func (e exemption) appliesTo(violation common.Violation) bool {
+	return slices.ContainsFunc(e.scopes, func(scope string) bool {
+		return scope == "*" ||
+			scope == violation.ValidatorName ||
+			(violation.RuleID != "" && scope == violation.ValidatorName+"/"+violation.RuleID)
+	})
+}
+
+/*
+exemptions are the exemptions of a validation run
+*/
+type exemptions struct {
+	annotated  map[common.ResourceKey]exemption // by the annotated resource
+	selectors  []selectorExemption
+	namespaces map[string]*unstructured.Unstructured // by name; only fetched if there are namespace selectors
+}
+
+/*
+readExemptions collects the valid, unexpired exemption annotations of the resources.
+Exemptions that lack a justification, have an invalid expiry or have expired are returned as violations.
+*/
+func readExemptions(resources []unstructured.Unstructured, now time.Time) (map[common.ResourceKey]exemption, []common.Violation) {
+	response := map[common.ResourceKey]exemption{}
+	var violations []common.Violation
+
+	for i := range resources {
+		resource := &resources[i]
+		annotations := resource.GetAnnotations()
+		value, found := annotations[ExemptionAnnotation]
+		if !found {
+			continue
+		}
+
+		newViolation := func(ruleID string, message string, remediation string) common.Violation {
+			return common.NewViolation(*resource, message, common.SeverityHigh.Level(), ExemptionsValidatorName).
+				WithRuleID(ruleID).
+				WithFieldPath(fmt.Sprintf("metadata.annotations[%s]", ExemptionAnnotation)).
+				WithRemediation(remediation).
+				WithDocumentationURL(exemptionDocumentationURL)
+		}
+
+		parsed := exemption{resource: resource, justification: strings.TrimSpace(annotations[ExemptionJustificationAnnotation])}
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				parsed.scopes = append(parsed.scopes, scope)
+			}
+		}
+
+		if parsed.justification == "" {
+			violations = append(violations, newViolation(ruleMissingJustification,
+				"exemption has no justification",
+				fmt.Sprintf("explain why the resource is exempt, in the %s annotation", ExemptionJustificationAnnotation)))
+			continue
+		}
+
+		if expiresValue, found := annotations[ExemptionExpiresAnnotation]; found {
+			expires, err := parseExpiry(expiresValue)
+			if err != nil {
+				violations = append(violations, newViolation(ruleInvalidExemptionExpiry,
+					fmt.Sprintf("exemption has an invalid expiry: %s", expiresValue),
+					fmt.Sprintf("set the %s annotation to a date (e.g. 2024-12-31) or an RFC 3339 timestamp", ExemptionExpiresAnnotation)))
+				continue
+			}
+
+			if !now.Before(expires) {
+				violation := newViolation(ruleExpiredExemption,
+					fmt.Sprintf("exemption expired on %s", expiresValue),
+					"resolve the violations and remove the exemption, or renew it").
+					WithDetail("exempt", value).
+					WithDetail("justification", parsed.justification)
+				violations = append(violations, violation)
+				continue
+			}
+			parsed.expires = &expires
+		}
+
+		response[common.NewResourceKey(resource)] = parsed
+	}
+
+	return response, violations
+}
+
+func parseExpiry(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if expires, err := time.Parse(time.RFC3339, value); err == nil {
+		return expires, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+/*
+apply returns the violations that are not exempt, and those that are.
+A violation is exempt if its resource, or one of the resource's owners (recursively), has an exemption annotation or matches an exemption selector,
+or if the resource's namespace matches an exemption namespace selector.
+index may be nil, in which case only the resource itself is considered.
+*/
+func (e exemptions) apply(violations []common.Violation, index *common.ResourceIndex) ([]common.Violation, []ExemptedViolation) {
+	if len(e.annotated) == 0 && len(e.selectors) == 0 {
+		return violations, nil
+	}
+
+	var reported []common.Violation
+	var exempted []ExemptedViolation
+	for _, violation := range violations {
+		if found, isExempt := e.find(violation, index); isExempt {
+			exempted = append(exempted, found)
+		} else {
+			reported = append(reported, violation)
+		}
+	}
+	return reported, exempted
+}
+
+func (e exemptions) find(violation common.Violation, index *common.ResourceIndex) (ExemptedViolation, bool) {
+	if violation.Resource == nil {
+		return ExemptedViolation{}, false
+	}
+
+	candidates := []*unstructured.Unstructured{violation.Resource}
+	if index != nil {
+		candidates = append(candidates, index.Ancestors(violation.Resource)...)
+	}
+
+	for _, candidate := range candidates {
+		// an annotation can't exempt the violations of exemption annotations
+		if found, ok := e.annotated[common.NewResourceKey(candidate)]; ok && violation.ValidatorName != ExemptionsValidatorName && found.appliesTo(violation) {
+			return ExemptedViolation{Violation: violation, ExemptedBy: found.resource, Justification: found.justification, Expires: found.expires}, true
+		}
+
+		for _, selectorExemption := range e.selectors {
+			if !selectorExemption.namespaced && selectorExemption.appliesTo(violation) && selectorExemption.selector.Matches(labels.Set(candidate.GetLabels())) {
+				return ExemptedViolation{Violation: violation, ExemptedBy: candidate, Justification: selectorExemption.justification()}, true
+			}
+		}
+	}
+
+	namespaceName := violation.Resource.GetNamespace()
+	if violation.Resource.GetKind() == common.KIND_NAMESPACE {
+		namespaceName = violation.Resource.GetName()
+	}
+	if namespace, found := e.namespaces[namespaceName]; found {
+		for _, selectorExemption := range e.selectors {
+			if selectorExemption.namespaced && selectorExemption.appliesTo(violation) && selectorExemption.selector.Matches(labels.Set(namespace.GetLabels())) {
+				return ExemptedViolation{Violation: violation, ExemptedBy: namespace, Justification: selectorExemption.justification()}, true
+			}
+		}
+	}
+
+	return ExemptedViolation{}, false
+}
@@ -3,6 +3,9 @@ package validation
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 	"github.com/go-logr/logr"
@@ -10,6 +13,15 @@ import (
 
 const LOGGER_NAME string = "k8s-resource-validator"
 
+/*
+LogViolationsBySeverity() writes violations to a logger, like LogViolations().
+
+violations of errorSeverity or more severe are considered errors; others are info
+*/
+func LogViolationsBySeverity(ctx context.Context, violations []common.Violation, errorSeverity common.Severity) error {
+	return LogViolations(ctx, violations, errorSeverity.Level())
+}
+
 /*
 LogViolations() writes violations to a logger.
 It is a convenience method. You may choose to write violations to the log in a different format.
@@ -19,6 +31,23 @@ ctx is expected to contain the logger
 thresholdLevelForErrors specifies that violations of this level or below are considered errors; others are info
 */
 func LogViolations(ctx context.Context, violations []common.Violation, thresholdLevelForErrors int) error {
+	reports := make([]ViolationReport, 0, len(violations))
+	for _, violation := range violations {
+		reports = append(reports, NewViolationReport(violation))
+	}
+	return logViolationReports(ctx, reports, thresholdLevelForErrors)
+}
+
+/*
+LogResultViolations() writes the violations of a result (e.g. of ValidateWithResult()) to a logger, like LogViolationsBySeverity().
+
+ctx is expected to contain the logger
+*/
+func LogResultViolations(ctx context.Context, result *Result, errorSeverity common.Severity) error {
+	return logViolationReports(ctx, result.Violations, errorSeverity.Level())
+}
+
+func logViolationReports(ctx context.Context, violations []ViolationReport, thresholdLevelForErrors int) error {
 	logger, err := logr.FromContext(ctx)
 	if err != nil {
 		return err
@@ -31,14 +60,15 @@ func LogViolations(ctx context.Context, violations []common.Violation, threshold
 
 	for _, violation := range violations {
 		resourceString := fmt.Sprintf("name: %s; namespace: %s, kind: %s",
-			violation.Resource.GetName(),
-			violation.Resource.GetNamespace(),
-			violation.Resource.GetKind(),
+			violation.Resource.Name,
+			violation.Resource.Namespace,
+			violation.Resource.Kind,
 		)
-		violationString := fmt.Sprintf("validator: %s; message: %s",
-			violation.ValidatorName,
+		violationString := fmt.Sprintf("validator: %s; severity: %s; message: %s",
+			violation.Validator,
+			violation.Severity,
 			violation.Message,
-		)
+		) + describeViolation(violation)
 
 		if violation.Level <= thresholdLevelForErrors {
 			logger.WithName(LOGGER_NAME).Error(nil, "error violations", resourceString, violationString)
@@ -49,3 +79,56 @@ func LogViolations(ctx context.Context, violations []common.Violation, threshold
 
 	return nil
 }
+
+/*
+LogOutcome() writes the outcome of a validation run to a logger, so that an aborted or failed run isn't mistaken for a run that found no violations.
+Aborted and failed runs are logged at level 0; completed runs at level 1.
+
+ctx is expected to contain the logger
+*/
+func LogOutcome(ctx context.Context, result *Result) error {
+	logger, err := logr.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger = logger.WithName(LOGGER_NAME)
+	switch result.Outcome {
+	case OutcomeAborted:
+		logger.Info(fmt.Sprintf("validation run was aborted: %s", result.AbortReason), "outcome", result.Outcome)
+	case OutcomeFailed:
+		logger.Error(nil, fmt.Sprintf("validation run failed with %d errors; its %d violations may be incomplete", len(result.Errors), len(result.Violations)),
+			"outcome", result.Outcome, "errors", strings.Join(result.Errors, "; "))
+	default:
+		logger.V(1).Info(fmt.Sprintf("validation run completed with %d violations", len(result.Violations)), "outcome", result.Outcome)
+	}
+	return nil
+}
+
+/*
+describeViolation renders the optional structured fields of a violation, e.g. "; rule: privileged; field: spec.containers[0].securityContext.privileged"
+*/
+func describeViolation(violation ViolationReport) string {
+	var response strings.Builder
+	for _, field := range []struct{ name, value string }{
+		{"rule", violation.Rule},
+		{"field", violation.FieldPath},
+		{"container", violation.Container},
+		{"remediation", violation.Remediation},
+		{"documentation", violation.DocumentationURL},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&response, "; %s: %s", field.name, field.value)
+		}
+	}
+
+	if len(violation.Details) > 0 {
+		details := make([]string, 0, len(violation.Details))
+		for _, key := range slices.Sorted(maps.Keys(violation.Details)) {
+			details = append(details, fmt.Sprintf("%s=%s", key, violation.Details[key]))
+		}
+		fmt.Fprintf(&response, "; details: %s", strings.Join(details, ", "))
+	}
+
+	return response.String()
+}
@@ -0,0 +1,212 @@
+package validation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+/*
+Outcome states how a validation run ended
+*/
+type Outcome string
+
+const (
+	OutcomeCompleted Outcome = "Completed" // all validators ran without errors
+	OutcomeAborted   Outcome = "Aborted"   // an abort condition was met, so no validator ran
+	OutcomeFailed    Outcome = "Failed"    // errors occurred, so the violations may be incomplete
+)
+
+/*
+Result is a serializable summary of a single validation run.
+Only the violations of Completed runs are complete: an Aborted run has no violations, even if the cluster has some.
+*/
+type Result struct {
+	Outcome    Outcome           `json:"outcome"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Namespaces []string          `json:"namespaces,omitempty"` // empty if the run was not scoped to namespaces
+	Validators []string          `json:"validators"`
+	Violations []ViolationReport `json:"violations"`
+	Suppressed int               `json:"suppressed"` // number of violations suppressed by the baseline
+	/*
+		BaselineCandidates lists the violations that the baseline was applied to (including the suppressed ones), i.e. before post-processing.
+		Unlike Violations, their fingerprints match on the next run when post-processors merge or roll up violations (see NewBaselineFromResult).
+	*/
+	BaselineCandidates []ViolationReport `json:"baselineCandidates,omitempty"`
+	Exempted   []ExemptedReport  `json:"exempted,omitempty"`
+	Errors     []string          `json:"errors,omitempty"`
+	/*
+		AbortReason states the abort condition that was met, if the run was aborted (see AbortCondition).
+		Aborted runs have no violations.
+	*/
+	AbortReason string `json:"abortReason,omitempty"`
+	/*
+		ScopedOut lists the workloads that were rolling out, whose resources (including their dependents) were excluded from the run
+		(see common.RolloutsConfig)
+	*/
+	ScopedOut []common.ViolationTarget `json:"scopedOut,omitempty"`
+}
+
+/*
+ViolationReport is the serializable form of a common.Violation
+*/
+type ViolationReport struct {
+	Fingerprint      string                 `json:"fingerprint"`
+	Validator        string                 `json:"validator"`
+	Rule             string                 `json:"rule,omitempty"`
+	Message          string                 `json:"message"`
+	Level            int                    `json:"level"`
+	Severity         common.Severity        `json:"severity"`
+	Resource         common.ViolationTarget `json:"resource"`
+	FieldPath        string                 `json:"fieldPath,omitempty"`
+	Container        string                 `json:"container,omitempty"`
+	Remediation      string                 `json:"remediation,omitempty"`
+	DocumentationURL string                 `json:"documentationUrl,omitempty"`
+	Details          map[string]string      `json:"details,omitempty"`
+}
+
+func NewViolationReport(violation common.Violation) ViolationReport {
+	return ViolationReport{
+		Fingerprint:      violation.Fingerprint(),
+		Validator:        violation.ValidatorName,
+		Rule:             violation.RuleID,
+		Message:          violation.Message,
+		Level:            violation.Level,
+		Severity:         violation.Severity(),
+		Resource:         common.NewViolationTarget(violation.Resource),
+		FieldPath:        violation.FieldPath,
+		Container:        violation.ContainerName,
+		Remediation:      violation.Remediation,
+		DocumentationURL: violation.DocumentationURL,
+		Details:          violation.Details,
+	}
+}
+
+/*
+ExemptedReport is the serializable form of an ExemptedViolation
+*/
+type ExemptedReport struct {
+	ViolationReport
+	ExemptedBy    common.ViolationTarget `json:"exemptedBy"`
+	Justification string                 `json:"justification"`
+	Expires       *time.Time             `json:"expires,omitempty"`
+}
+
+func NewExemptedReport(exempted ExemptedViolation) ExemptedReport {
+	return ExemptedReport{
+		ViolationReport: NewViolationReport(exempted.Violation),
+		ExemptedBy:      common.NewViolationTarget(exempted.ExemptedBy),
+		Justification:   exempted.Justification,
+		Expires:         exempted.Expires,
+	}
+}
+
+/*
+NewResult summarizes the return values of Validate() into a Result.
+Its outcome is Failed if err is set, and Completed otherwise; Validate() doesn't report aborted runs (see ValidateWithResult()).
+err may be a joined error, in which case each of the joined errors is listed separately.
+*/
+func NewResult(startedAt time.Time, validators []common.Validator, namespaces []string, violations []common.Violation, err error) *Result {
+	response := Result{
+		Outcome:    OutcomeCompleted,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Namespaces: namespaces,
+		Validators: make([]string, 0, len(validators)),
+		Violations: make([]ViolationReport, 0, len(violations)),
+	}
+
+	for _, validator := range validators {
+		response.Validators = append(response.Validators, validator.GetName())
+	}
+
+	for _, violation := range violations {
+		response.Violations = append(response.Violations, NewViolationReport(violation))
+	}
+
+	if err != nil {
+		response.Outcome = OutcomeFailed
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				response.Errors = append(response.Errors, e.Error())
+			}
+		} else {
+			response.Errors = append(response.Errors, err.Error())
+		}
+	}
+
+	return &response
+}
+
+/*
+ResultHistory retains the most recent validation results in memory.
+It is safe for concurrent use, so that e.g. a scheduler can record results while an HTTP server reads them.
+*/
+type ResultHistory struct {
+	mutex    sync.RWMutex
+	size     int
+	results  []*Result
+	outcomes map[Outcome]int64 // of all results added, including those that are no longer retained
+}
+
+/*
+NewResultHistory creates a history that retains up to size results (at least one)
+*/
+func NewResultHistory(size int) *ResultHistory {
+	if size < 1 {
+		size = 1
+	}
+	return &ResultHistory{size: size, outcomes: map[Outcome]int64{}}
+}
+
+func (h *ResultHistory) Add(result *Result) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.outcomes[result.Outcome]++
+	h.results = append(h.results, result)
+	if len(h.results) > h.size {
+		h.results = h.results[len(h.results)-h.size:]
+	}
+}
+
+/*
+Last returns the most recent result, or nil if there is none
+*/
+func (h *ResultHistory) Last() *Result {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if len(h.results) == 0 {
+		return nil
+	}
+	return h.results[len(h.results)-1]
+}
+
+/*
+All returns the retained results, oldest first
+*/
+func (h *ResultHistory) All() []*Result {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	response := make([]*Result, len(h.results))
+	copy(response, h.results)
+	return response
+}
+
+/*
+Outcomes counts the results added so far by their outcome (e.g. for exposing them as metrics), including those that are no longer retained
+*/
+func (h *ResultHistory) Outcomes() map[Outcome]int64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	response := map[Outcome]int64{OutcomeCompleted: 0, OutcomeAborted: 0, OutcomeFailed: 0}
+	for outcome, count := range h.outcomes {
+		response[outcome] = count
+	}
+	return response
+}
@@ -0,0 +1,210 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	RolloutModeWait     = "wait"     // wait for rollouts to complete, and abort if they don't complete in time
+	RolloutModeScopeOut = "scopeOut" // exclude workloads that are rolling out, and their dependents, from runs
+
+	defaultRolloutTimeout      = 10 * time.Minute
+	defaultRolloutPollInterval = 15 * time.Second
+)
+
+// the kinds whose rollouts are detected
+var rolloutKinds = []string{common.KIND_DEPLOYMENT, common.KIND_STATEFUL_SET, common.KIND_DAEMON_SET, common.KIND_REPLICA_SET}
+
+/*
+rolloutDetection handles the workloads that are rolling out, as configured by the rollouts section of config.yaml
+*/
+type rolloutDetection struct {
+	mode         string // empty if disabled
+	kinds        []string
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+/*
+rollout is a workload that is rolling out
+*/
+type rollout struct {
+	resource *unstructured.Unstructured
+	reason   string
+}
+
+func (r rollout) String() string {
+	return fmt.Sprintf("%s is rolling out (%s)", common.NewResourceKey(r.resource), r.reason)
+}
+
+func newRolloutDetection(config common.RolloutsConfig) (rolloutDetection, error) {
+	response := rolloutDetection{
+		mode:         config.Mode,
+		kinds:        config.Kinds,
+		timeout:      config.Timeout,
+		pollInterval: config.PollInterval,
+	}
+
+	switch config.Mode {
+	case "", RolloutModeWait, RolloutModeScopeOut:
+	default:
+		// runs are aborted while workloads are rolling out by the rollout abort condition instead
+		return response, fmt.Errorf("unknown mode %q: expected %s or %s (use a rollout abort condition to abort runs)", config.Mode, RolloutModeWait, RolloutModeScopeOut)
+	}
+
+	if len(response.kinds) == 0 {
+		response.kinds = rolloutKinds
+	}
+	for _, kind := range response.kinds {
+		if !slices.Contains(rolloutKinds, kind) {
+			return response, fmt.Errorf("rollout of unsupported kind %s", kind)
+		}
+	}
+
+	if response.timeout < 0 || response.pollInterval < 0 {
+		return response, errors.New("timeout and pollInterval must not be negative")
+	}
+	if response.timeout == 0 {
+		response.timeout = defaultRolloutTimeout
+	}
+	if response.pollInterval == 0 {
+		response.pollInterval = defaultRolloutPollInterval
+	}
+	return response, nil
+}
+
+/*
+detect returns the workloads that are rolling out
+*/
+func (d rolloutDetection) detect(resources []unstructured.Unstructured) []rollout {
+	var response []rollout
+	for i := range resources {
+		resource := &resources[i]
+		if !slices.Contains(d.kinds, resource.GetKind()) {
+			continue
+		}
+		if rollingOut, reason := isRollingOut(resource); rollingOut {
+			response = append(response, rollout{resource: resource, reason: reason})
+		}
+	}
+	return response
+}
+
+/*
+scopeOut returns the resources, except for the workloads that are rolling out and their dependents (e.g. their ReplicaSets and Pods),
+as well as the excluded resources
+*/
+func scopeOut(index *common.ResourceIndex, rollouts []rollout) ([]unstructured.Unstructured, []unstructured.Unstructured) {
+	rollingOut := map[common.ResourceKey]bool{}
+	for _, rollout := range rollouts {
+		rollingOut[common.NewResourceKey(rollout.resource)] = true
+	}
+
+	var response, excludedResources []unstructured.Unstructured
+	for _, resource := range index.Resources() {
+		excluded := rollingOut[common.NewResourceKey(&resource)]
+		for _, ancestor := range index.Ancestors(&resource) {
+			excluded = excluded || rollingOut[common.NewResourceKey(ancestor)]
+		}
+		if excluded {
+			excludedResources = append(excludedResources, resource)
+		} else {
+			response = append(response, resource)
+		}
+	}
+	return response, excludedResources
+}
+
+/*
+describeRollouts summarizes rollouts for logs and abort reasons
+*/
+func describeRollouts(rollouts []rollout) string {
+	if len(rollouts) == 1 {
+		return rollouts[0].String()
+	}
+	return fmt.Sprintf("%s, and %d more workloads are rolling out", rollouts[0], len(rollouts)-1)
+}
+
+/*
+abortReason states why rollouts, which didn't complete while waiting for them, abort a run
+*/
+func (d rolloutDetection) abortReason(rollouts []rollout) string {
+	return fmt.Sprintf("%s, and didn't complete within %s: Aborting validation", describeRollouts(rollouts), d.timeout)
+}
+
+/*
+isRollingOut returns true (and the reason) if the workload's controller hasn't yet observed its latest spec,
+or if not all of its replicas are updated and available
+*/
+func isRollingOut(resource *unstructured.Unstructured) (bool, string) {
+	generation := resource.GetGeneration()
+	observedGeneration, _, _ := unstructured.NestedInt64(resource.Object, "status", "observedGeneration")
+	if generation > observedGeneration {
+		return true, fmt.Sprintf("generation %d is not observed yet", generation)
+	}
+
+	if resource.GetKind() == common.KIND_DAEMON_SET {
+		desired, _, _ := unstructured.NestedInt64(resource.Object, "status", "desiredNumberScheduled")
+		updated, _, _ := unstructured.NestedInt64(resource.Object, "status", "updatedNumberScheduled")
+		if updated < desired {
+			return true, fmt.Sprintf("%d of %d scheduled pods are updated", updated, desired)
+		}
+		available, _, _ := unstructured.NestedInt64(resource.Object, "status", "numberAvailable")
+		if available < desired {
+			return true, fmt.Sprintf("%d of %d scheduled pods are available", available, desired)
+		}
+		return false, ""
+	}
+
+	replicas, found, _ := unstructured.NestedInt64(resource.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	statusReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "replicas")
+
+	switch resource.GetKind() {
+	case common.KIND_DEPLOYMENT:
+		updatedReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "updatedReplicas")
+		if updatedReplicas < replicas {
+			return true, fmt.Sprintf("%d of %d replicas are updated", updatedReplicas, replicas)
+		}
+		// old replicas are still terminating
+		if statusReplicas > updatedReplicas {
+			return true, fmt.Sprintf("%d old replicas are pending termination", statusReplicas-updatedReplicas)
+		}
+		availableReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "availableReplicas")
+		if availableReplicas < updatedReplicas {
+			return true, fmt.Sprintf("%d of %d updated replicas are available", availableReplicas, updatedReplicas)
+		}
+	case common.KIND_STATEFUL_SET:
+		updatedReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "updatedReplicas")
+		if updatedReplicas < replicas {
+			return true, fmt.Sprintf("%d of %d replicas are updated", updatedReplicas, replicas)
+		}
+		currentRevision, _, _ := unstructured.NestedString(resource.Object, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(resource.Object, "status", "updateRevision")
+		if updateRevision != "" && currentRevision != updateRevision {
+			return true, fmt.Sprintf("revision %s is not current yet", updateRevision)
+		}
+		readyReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "readyReplicas")
+		if readyReplicas < replicas {
+			return true, fmt.Sprintf("%d of %d replicas are ready", readyReplicas, replicas)
+		}
+	case common.KIND_REPLICA_SET:
+		if statusReplicas != replicas {
+			return true, fmt.Sprintf("scaling from %d to %d replicas", statusReplicas, replicas)
+		}
+		availableReplicas, _, _ := unstructured.NestedInt64(resource.Object, "status", "availableReplicas")
+		if availableReplicas < replicas {
+			return true, fmt.Sprintf("%d of %d replicas are available", availableReplicas, replicas)
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+/*
+SeverityOverride sets the severity of the violations of a validator, or of one of its rules (see common.SeverityOverride)
+*/
+type SeverityOverride = common.SeverityOverride
+
+type SeverityOverrides []SeverityOverride
+
+/*
+Validate returns an error if an override doesn't specify a validator or a known severity
+*/
+func (s SeverityOverrides) Validate() error {
+	for i, override := range s {
+		if override.Validator == "" {
+			return fmt.Errorf("severity override %d: validator is empty", i)
+		}
+		severity, err := common.ParseSeverity(string(override.Severity))
+		if err != nil {
+			return fmt.Errorf("severity override %d: %w", i, err)
+		}
+		s[i].Severity = severity
+	}
+	return nil
+}
+
+/*
+Apply returns the violations with their overridden severities
+*/
+func (s SeverityOverrides) Apply(violations []common.Violation) []common.Violation {
+	if len(s) == 0 {
+		return violations
+	}
+
+	response := make([]common.Violation, 0, len(violations))
+	for _, violation := range violations {
+		if severity, found := s.lookup(violation.ValidatorName, violation.RuleID); found {
+			violation = violation.WithSeverity(severity)
+		}
+		response = append(response, violation)
+	}
+	return response
+}
+
+func (s SeverityOverrides) lookup(validatorName string, ruleID string) (common.Severity, bool) {
+	var response common.Severity
+	found := false
+	for _, override := range s {
+		if override.Validator != validatorName {
+			continue
+		}
+		if override.Rule == "" && !found {
+			response, found = override.Severity, true
+		} else if override.Rule != "" && override.Rule == ruleID {
+			return override.Severity, true
+		}
+	}
+	return response, found
+}
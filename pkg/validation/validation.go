@@ -7,10 +7,13 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
-	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/SAP/k8s-resource-validator/pkg/postprocessing"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -18,45 +21,62 @@ import (
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 
-	koanfYaml "github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/rawbytes"
-	"github.com/knadh/koanf/v2"
-
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const (
 	additionalResourceTypesFile = "additionalResourceTypes.yaml"
 	configFileName              = "config.yaml"
+	strictConfigEnv             = "CONFIG_STRICT"
 	defaultConfigDirectory      = "/config/"
 )
 
 type Validation struct {
 	Client                            *K8SProvider
 	Resources                         []unstructured.Unstructured
+	resourceIndex                     *common.ResourceIndex
 	AbortValidationConfigMapField     string
 	AbortValidationConfigMapName      string
 	AbortValidationConfigMapNamespace string
-	abortFunc                         common.AbortFunc
+	abortCondition                    AbortCondition // of config.Abort; nil if the legacy ConfigMap fields above apply
+	customAbortCondition              AbortCondition // replaces abortCondition, if set
+	rollouts                          rolloutDetection
+	scopedOut                         []*unstructured.Unstructured // workloads that were rolling out when the resources were fetched
+	scopedOutResources                []unstructured.Unstructured  // the scopedOut workloads, and their dependents
+	rolloutAbortReason                string                       // set if rollouts that didn't complete in time abort runs on the collected resources
+	config                            common.Config
+	configLoader                      ConfigLoader // the layers of config, which are read again by Reload()
+	baseline                          Baseline
+	baselineIsSet                     bool
+	exemptionSelectors                []selectorExemption
+	namespaces                        map[string]*unstructured.Unstructured // only fetched if there are namespace exemption selectors
+	severityOverrides                 SeverityOverrides
+	postProcessors                    []common.PostProcessor
 	ctx                               context.Context
 	appFs                             afero.Fs
 	logger                            logr.Logger
-	PreValidated                      bool
+	PreValidated                      bool       // true once the resources are collected, until Refresh()
+	runsMutex                         sync.Mutex // serializes runs (and their phases, and Refresh()), including their waiting for rollouts
+	runMutex                          sync.Mutex // guards the state of runs and the configuration; released while a run waits for rollouts
+	clientMutex                       sync.Mutex // guards Client creation
 }
 
 func NewValidation(ctx context.Context) (*Validation, error) {
 	response := Validation{}
 	response.ctx = ctx
 	response.appFs = ctx.Value(common.FileSystemContextKey).(afero.Fs)
+	response.logger = logr.FromContextOrDiscard(ctx)
 
-	response.loadConfiguration()
+	// set default values, which config.yaml may override
+	if err := response.applyConfig(common.NewConfig()); err != nil {
+		return nil, err
+	}
 
-	response.PreValidated = false
+	if err := response.loadConfiguration(); err != nil {
+		return nil, err
+	}
 
-	// set default values
-	response.AbortValidationConfigMapField = "deploying"
-	response.AbortValidationConfigMapName = "landscape-state"
-	response.AbortValidationConfigMapNamespace = "center"
+	response.PreValidated = false
 
 	return &response, nil
 }
@@ -75,72 +95,511 @@ func (v *Validation) SetLogger(logger logr.Logger) {
 }
 
 func (v *Validation) SetClient(client *K8SProvider) {
+	v.clientMutex.Lock()
+	defer v.clientMutex.Unlock()
 	v.Client = client
 }
 
+/*
+SetAbortFunc replaces the abort conditions with abortFunc.
+See SetAbortConditions() for combining custom conditions with others.
+*/
 func (v *Validation) SetAbortFunc(abortFunc common.AbortFunc) {
-	v.abortFunc = abortFunc
+	v.SetAbortConditions(AbortConditionFunc(func(context.Context, AbortState) (bool, string, error) {
+		abort, err := abortFunc()
+		if abort {
+			return true, "Abort function returned true: Aborting validation", err
+		}
+		return false, "Abort function returned false: Resuming validation", err
+	}))
 }
 
-func (v *Validation) preValidate() (bool, error) {
-	if !v.PreValidated {
-		if v.Client == nil {
-			var err error
-			v.Client, err = getClient()
-			if err != nil {
-				v.logger.Error(err, "unable to create client")
-				return false, err
-			}
+/*
+SetAbortConditions replaces the abort conditions read from config.yaml; the run is aborted if any of conditions is met.
+In order to keep the configured conditions, combine them with the custom ones, e.g.
+
+	configured, err := validation.NewAbortConditions(validationInstance.GetConfig().Abort)
+	validationInstance.SetAbortConditions(configured, validation.AbortConditionFunc(myCondition))
+*/
+func (v *Validation) SetAbortConditions(conditions ...AbortCondition) {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	v.customAbortCondition = AnyOf(conditions...)
+}
+
+/*
+SetSeverityOverrides replaces the severity overrides read from config.yaml
+*/
+func (v *Validation) SetSeverityOverrides(overrides SeverityOverrides) error {
+	if err := overrides.Validate(); err != nil {
+		return err
+	}
+
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	v.severityOverrides = overrides
+	return nil
+}
+
+/*
+SetConfig replaces the configuration read from config.yaml
+*/
+func (v *Validation) SetConfig(config common.Config) error {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return v.applyConfig(config)
+}
+
+/*
+LoadConfig replaces the configuration read from config.yaml with the layers of loader (see ConfigLoader).
+It connects to the cluster if the loader reads a ConfigMap or Secret. Warnings are logged.
+*/
+func (v *Validation) LoadConfig(loader ConfigLoader) (*LoadedConfig, error) {
+	if loader.ConfigMap != nil || loader.Secret != nil {
+		if err := v.Connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	loaded, err := loader.Load(v.ctx, v.appFs, v.Client)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range loaded.Warnings {
+		v.logger.Info("ignoring invalid configuration: " + warning)
+	}
+
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	if err = v.applyConfig(loaded.Config); err != nil {
+		return nil, err
+	}
+	v.configLoader = loader
+	return loaded, nil
+}
+
+/*
+WatchedFiles returns the configuration files, i.e. config.yaml or the files of the ConfigLoader passed to LoadConfig()
+*/
+func (v *Validation) WatchedFiles() []string {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return slices.Clone(v.configLoader.Files)
+}
+
+/*
+Reload reads the configuration layers again (see LoadConfig()), and applies them if they are valid.
+Runs that are in progress complete with the previous configuration.
+*/
+func (v *Validation) Reload() error {
+	v.runMutex.Lock()
+	loader := v.configLoader
+	v.runMutex.Unlock()
+
+	_, err := v.LoadConfig(loader)
+	return err
+}
+
+/*
+GetConfig returns the configuration of this Validation
+*/
+func (v *Validation) GetConfig() common.Config {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return v.config
+}
+
+/*
+SetExemptions replaces the exemptions read from config.yaml.
+Exemptions are applied to the violations of all validators, before severity overrides.
+*/
+func (v *Validation) SetExemptions(exemptionConfig common.ExemptionConfig) error {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+
+	config := v.config
+	config.Exempt = exemptionConfig
+	return v.applyConfig(config)
+}
+
+/*
+applyConfig validates the configuration, and applies it to this Validation
+*/
+func (v *Validation) applyConfig(config common.Config) error {
+	selectors, err := parseExemptionConfig(config.Exempt)
+	if err != nil {
+		return fmt.Errorf("invalid exempt: %w", err)
+	}
+
+	severityOverrides := SeverityOverrides(slices.Clone(config.Severities))
+	if err = severityOverrides.Validate(); err != nil {
+		return fmt.Errorf("invalid severities: %w", err)
+	}
+
+	postProcessors, err := postprocessing.NewPostProcessors(config.PostProcessors)
+	if err != nil {
+		return fmt.Errorf("invalid postProcessors: %w", err)
+	}
+
+	var abortCondition AbortCondition
+	if len(config.Abort.Conditions) > 0 {
+		if abortCondition, err = NewAbortConditions(config.Abort); err != nil {
+			return fmt.Errorf("invalid abort: %w", err)
 		}
+	}
+
+	rollouts, err := newRolloutDetection(config.Rollouts)
+	if err != nil {
+		return fmt.Errorf("invalid rollouts: %w", err)
+	}
+
+	v.config = config
+	v.exemptionSelectors = selectors
+	v.severityOverrides = severityOverrides
+	v.postProcessors = postProcessors
+	v.abortCondition = abortCondition
+	v.rollouts = rollouts
+	defaults := common.NewConfig().Abort
+	v.AbortValidationConfigMapNamespace = abortConfigMapField(v.AbortValidationConfigMapNamespace, config.Abort.ConfigMapNamespace, defaults.ConfigMapNamespace)
+	v.AbortValidationConfigMapName = abortConfigMapField(v.AbortValidationConfigMapName, config.Abort.ConfigMapName, defaults.ConfigMapName)
+	v.AbortValidationConfigMapField = abortConfigMapField(v.AbortValidationConfigMapField, config.Abort.ConfigMapField, defaults.ConfigMapField)
+	return nil
+}
 
-		configDir := resolveConfigDirectory()
+/*
+abortConfigMapField returns the value of an AbortValidationConfigMap... field: the configured value, if it's set (to other than the default),
+and otherwise the current value, so that the values set by the caller survive SetConfig() and Reload()
+*/
+func abortConfigMapField(current string, configured string, defaultValue string) string {
+	if current != "" && (configured == "" || configured == defaultValue) {
+		return current
+	}
+	return configured
+}
+
+/*
+SetPostProcessors replaces the post-processors read from config.yaml.
+Post-processors are applied in order, after severity overrides and the baseline.
+*/
+func (v *Validation) SetPostProcessors(postProcessors ...common.PostProcessor) {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	v.postProcessors = postProcessors
+}
+
+/*
+SetBaseline sets the known violations that are suppressed.
+If this function is not called, the baseline is read from the configuration directory (if present).
+*/
+func (v *Validation) SetBaseline(baseline Baseline) {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	v.baseline = baseline
+	v.baselineIsSet = true
+}
+
+/*
+Connect creates a client for the target cluster, unless one was already set (see SetClient())
+*/
+func (v *Validation) Connect() error {
+	v.clientMutex.Lock()
+	defer v.clientMutex.Unlock()
 
-		additionalResourceTypes, err := v.readAdditionalResourceTypes(configDir)
+	if v.Client == nil {
+		client, err := getClient()
 		if err != nil {
-			return false, err
+			v.logger.Error(err, "unable to create client")
+			return err
 		}
+		v.Client = client
+	}
 
-		v.Resources = fetchResources(v.ctx, *v.Client, additionalResourceTypes)
+	return nil
+}
 
-		if v.abortFunc == nil {
-			shouldAbort, abortMessage, err := v.shouldAbortValidation(v.ctx, *v.Client)
-			if err != nil {
-				v.logger.Error(err, "error determining whether should abort the validation")
-				return true, err
-			}
+/*
+Ping connects to the target cluster (see Connect()), and requests the version of its API server, in order to verify that it's reachable.
+It fails if the API server doesn't respond before ctx is done.
+*/
+func (v *Validation) Ping(ctx context.Context) error {
+	if err := v.Connect(); err != nil {
+		return err
+	}
 
-			v.logger.V(2).Info(abortMessage)
-			if shouldAbort {
-				return true, nil
-			}
-		} else {
-			return v.abortFunc()
+	v.clientMutex.Lock()
+	client := v.Client
+	v.clientMutex.Unlock()
+	if client.ClientSet == nil {
+		return errors.New("no client for the target cluster")
+	}
+
+	// ServerVersion() doesn't take a context
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.ClientSet.Discovery().ServerVersion()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("the API server didn't respond: %w", ctx.Err())
+	}
+}
+
+/*
+Collect is the first phase of a run: it fetches the cluster's resources (and reads the baseline, unless it's set),
+and handles the workloads that are rolling out (see common.RolloutsConfig).
+
+The collected resources are cached until Refresh() is called, so that subsequent calls of Collect(), ShouldAbort(), Evaluate()
+and Validate() use the same resources.
+*/
+func (v *Validation) Collect() error {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return v.collect()
+}
+
+func (v *Validation) collect() error {
+	if v.PreValidated {
+		return nil
+	}
+
+	if err := v.Connect(); err != nil {
+		return err
+	}
+
+	configDir := resolveConfigDirectory()
+
+	additionalResourceTypes, err := v.readAdditionalResourceTypes(configDir)
+	if err != nil {
+		return err
+	}
+	for _, resourceType := range v.config.Collection.AdditionalResourceTypes {
+		additionalResourceTypes = append(additionalResourceTypes, schema.GroupVersionResource{Group: resourceType.Group, Version: resourceType.Version, Resource: resourceType.Resource})
+	}
+
+	if !v.baselineIsSet {
+		v.baseline, err = v.readBaseline(configDir)
+		if err != nil {
+			return err
 		}
+	}
+
+	v.Resources = fetchResources(v.ctx, *v.Client, additionalResourceTypes)
+	v.resourceIndex = v.newResourceIndex(v.Resources)
+	v.namespaces = nil // fetched by evaluate(), if needed
+
+	if err = v.handleRollouts(additionalResourceTypes); err != nil {
+		return err
+	}
+
+	v.PreValidated = true
+	return nil
+}
+
+/*
+ShouldAbort is the second phase of a run: it evaluates the abort conditions (and rollouts) against the collected resources,
+collecting them if needed. If the run is to be aborted, it returns the reason.
+
+Unlike the collected resources, the outcome of ShouldAbort() isn't cached: the conditions are evaluated on each call.
+*/
+func (v *Validation) ShouldAbort() (bool, string, error) {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return v.shouldAbort()
+}
+
+func (v *Validation) shouldAbort() (bool, string, error) {
+	if err := v.collect(); err != nil {
+		return false, "", err
+	}
+
+	if v.rolloutAbortReason != "" {
+		v.logger.V(1).Info(v.rolloutAbortReason)
+		return true, v.rolloutAbortReason, nil
+	}
+
+	abort, message, err := v.abortConditions().ShouldAbort(v.ctx, AbortState{Client: v.Client, Resources: v.Resources, Now: time.Now()})
+	if err != nil {
+		v.logger.Error(err, "error determining whether should abort the validation")
+		return false, "", err
+	}
 
-		v.PreValidated = true
+	v.logger.V(2).Info(message)
+	if abort && message == "" {
+		message = "an abort condition was met"
 	}
+	return abort, message, nil
+}
+
+/*
+Evaluate is the last phase of a run: it runs the validators against the collected resources (collecting them if needed),
+and applies the exemptions, severity overrides, post-processors and baseline to their violations.
 
-	return false, nil
+Evaluate() doesn't evaluate the abort conditions; call ShouldAbort() first, or use Validate(), which performs all phases.
+*/
+func (v *Validation) Evaluate(validators []common.Validator) ([]common.Violation, error) {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+
+	output, err := v.evaluate(validators)
+	return output.violations, err
 }
 
 /*
+Refresh discards the collected resources, so that the next run (or call of Collect()) fetches the cluster's resources anew
+(by default, resources are fetched only once per Validation instance)
+*/
+func (v *Validation) Refresh() {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	v.PreValidated = false
+}
+
+/*
+Validate performs a run, i.e. Collect(), ShouldAbort() and Evaluate().
 returns a slice of violations (empty if no violations are found)
+violations that are suppressed by the baseline, or whose resources are exempt, are not returned
+
+if an abort condition is met, no validator runs, and neither violations nor an error are returned
+(use ValidateWithResult(), or the phases of the run, to tell aborted runs apart).
+The resources of an aborted run are discarded, so that the next run fetches them anew.
+
+Validate is safe for concurrent use; concurrent calls are serialized.
 */
 func (v *Validation) Validate(validators []common.Validator) ([]common.Violation, error) {
-	var cumulativeErr error
-	var violations []common.Violation
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+
+	output, err := v.validate(validators)
+	return output.violations, err
+}
+
+/*
+ValidateWithResult performs a validation run like Validate(), and summarizes it as a Result.
+Unlike Validate(), it distinguishes aborted runs from runs that found no violations, by their Outcome and AbortReason.
+If namespaces are given, only violations of resources in these namespaces are included.
+*/
+func (v *Validation) ValidateWithResult(validators []common.Validator, namespaces []string) *Result {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+
+	return v.validateWithResult(validators, namespaces)
+}
+
+/*
+RefreshAndValidateWithResult performs a validation run like ValidateWithResult() on freshly fetched resources.
+Unlike calling Refresh() first, no other run can collect the resources in between.
+*/
+func (v *Validation) RefreshAndValidateWithResult(validators []common.Validator, namespaces []string) *Result {
+	v.runsMutex.Lock()
+	defer v.runsMutex.Unlock()
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+
+	v.PreValidated = false
+	return v.validateWithResult(validators, namespaces)
+}
+
+func (v *Validation) validateWithResult(validators []common.Validator, namespaces []string) *Result {
+	startedAt := time.Now()
+	output, err := v.validate(validators)
+
+	result := NewResult(startedAt, validators, namespaces, common.FilterViolationsByNamespace(output.violations, namespaces), err)
+	result.Suppressed = len(common.FilterViolationsByNamespace(output.suppressed, namespaces))
+	for _, candidate := range common.FilterViolationsByNamespace(output.candidates, namespaces) {
+		result.BaselineCandidates = append(result.BaselineCandidates, NewViolationReport(candidate))
+	}
+	if output.aborted {
+		result.Outcome = OutcomeAborted
+		result.AbortReason = output.abortReason
+	}
+	for _, workload := range output.scopedOut {
+		if len(namespaces) == 0 || slices.Contains(namespaces, workload.GetNamespace()) {
+			result.ScopedOut = append(result.ScopedOut, common.NewViolationTarget(workload))
+		}
+	}
+	for _, exempted := range output.exempted {
+		if len(common.FilterViolationsByNamespace([]common.Violation{exempted.Violation}, namespaces)) > 0 {
+			result.Exempted = append(result.Exempted, NewExemptedReport(exempted))
+		}
+	}
+	return result
+}
 
-	aborted, err := v.preValidate()
+/*
+runOutput holds the outcome of a single call to validate()
+*/
+type runOutput struct {
+	violations  []common.Violation // the violations to report
+	suppressed  []common.Violation // violations that are suppressed by the baseline
+	candidates  []common.Violation // the violations that the baseline is applied to, i.e. before post-processing
+	exempted    []ExemptedViolation
+	aborted     bool
+	abortReason string // if the run was aborted
+	scopedOut   []*unstructured.Unstructured
+}
+
+func (v *Validation) validate(validators []common.Validator) (runOutput, error) {
+	aborted, abortReason, err := v.shouldAbort()
 	if err != nil {
-		cumulativeErr = errors.Join(cumulativeErr, err)
-		return violations, cumulativeErr
+		return runOutput{}, err
 	}
 
 	if aborted {
-		return violations, nil
+		// the resources are likely in transition, so that they're not reused
+		v.PreValidated = false
+		return runOutput{aborted: true, abortReason: abortReason}, nil
+	}
+	return v.evaluate(validators)
+}
+
+func (v *Validation) evaluate(validators []common.Validator) (runOutput, error) {
+	var cumulativeErr error
+	var output runOutput
+
+	err := v.collect()
+	if err != nil {
+		return output, err
 	}
+	output.scopedOut = v.scopedOut
 
+	if v.namespaces == nil && hasNamespaceSelectors(v.exemptionSelectors) {
+		v.namespaces, err = fetchNamespaces(v.ctx, *v.Client)
+		if err != nil {
+			v.logger.Error(err, "couldn't fetch namespaces for exemptions")
+			return output, err
+		}
+	}
+
+	var violations []common.Violation
 	for _, validator := range validators {
+		if aware, ok := validator.(common.ResourceIndexAware); ok {
+			aware.SetResourceIndex(v.resourceIndex)
+		}
+		if aware, ok := validator.(common.ConfigAware); ok {
+			aware.SetConfig(v.config)
+		}
+		if aware, ok := validator.(common.ClientAware); ok {
+			aware.SetClientSet(v.Client.ClientSet)
+		}
+		if aware, ok := validator.(common.ScopeAware); ok {
+			aware.SetScopedOut(v.scopedOutResources)
+		}
+
 		newViolations, err := validator.Validate(v.Resources)
 		if err != nil {
 			cumulativeErr = errors.Join(cumulativeErr, err)
@@ -152,7 +611,64 @@ func (v *Validation) Validate(validators []common.Validator) ([]common.Violation
 		}
 	}
 
-	return violations, cumulativeErr
+	annotated, exemptionViolations := readExemptions(v.Resources, time.Now())
+	exemptions := exemptions{annotated: annotated, selectors: v.exemptionSelectors, namespaces: v.namespaces}
+	violations, output.exempted = exemptions.apply(violations, v.resourceIndex)
+	violations = append(violations, exemptionViolations...)
+	for _, exempted := range output.exempted {
+		v.logger.V(2).Info(fmt.Sprintf("violation of %s by %s is exempted by %s: %s",
+			exempted.Violation.ValidatorName, common.NewResourceKey(exempted.Violation.Resource), common.NewResourceKey(exempted.ExemptedBy), exempted.Justification))
+	}
+
+	violations = v.severityOverrides.Apply(violations)
+
+	// the baseline is applied first, so that post-processors (e.g. a merge or a limit) only see the violations that are reported.
+	// Its fingerprints therefore refer to the violations before post-processing, which results list as baseline candidates
+	output.candidates = violations
+	violations, output.suppressed = v.baseline.Apply(violations, time.Now())
+	if len(output.suppressed) > 0 {
+		v.logger.V(2).Info(fmt.Sprintf("%d violations are suppressed by the baseline", len(output.suppressed)))
+	}
+
+	for _, postProcessor := range v.postProcessors {
+		if aware, ok := postProcessor.(common.ResourceIndexAware); ok {
+			aware.SetResourceIndex(v.resourceIndex)
+		}
+	}
+	output.violations = postprocessing.Apply(v.postProcessors, violations, v.Resources)
+
+	return output, cumulativeErr
+}
+
+/*
+GetResourceIndex returns the index of the fetched resources (nil before the first call to Collect() or Validate())
+*/
+func (v *Validation) GetResourceIndex() *common.ResourceIndex {
+	v.runMutex.Lock()
+	defer v.runMutex.Unlock()
+	return v.resourceIndex
+}
+
+/*
+newResourceIndex indexes the fetched resources, and logs problems with their ownership relations
+*/
+func (v *Validation) newResourceIndex(resources []unstructured.Unstructured) *common.ResourceIndex {
+	index := common.NewResourceIndex(resources)
+
+	for _, dangling := range index.DanglingOwnerReferences() {
+		v.logger.V(1).Info(fmt.Sprintf("dangling owner reference: %s is owned by %s %s, which doesn't exist",
+			common.NewResourceKey(dangling.Dependent), dangling.Reference.Kind, dangling.Reference.Name))
+	}
+
+	for _, cycle := range index.OwnershipCycles() {
+		keys := make([]string, 0, len(cycle))
+		for _, resource := range cycle {
+			keys = append(keys, common.NewResourceKey(resource).String())
+		}
+		v.logger.V(1).Info(fmt.Sprintf("ownership cycle (each resource is owned by the next one): %s", strings.Join(keys, " -> ")))
+	}
+
+	return index
 }
 
 func (v *Validation) readAdditionalResourceTypes(dir string) ([]schema.GroupVersionResource, error) {
@@ -163,7 +679,7 @@ func (v *Validation) readAdditionalResourceTypes(dir string) ([]schema.GroupVers
 	content, err := afero.ReadFile(v.appFs, additionalResourceTypesFullPath)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			v.logger.V(0).Info("couldn't find additional resource types file", additionalResourceTypesFullPath)
+			v.logger.V(0).Info("couldn't find additional resource types file", "path", additionalResourceTypesFullPath)
 			return nil, nil
 		}
 		v.logger.Error(err, "couldn't read additional resource types file")
@@ -189,65 +705,117 @@ func resolveConfigDirectory() string {
 }
 
 /*
-	returns true if validation should be aborted. E.g. resources are currently being deployed.
+handleRollouts handles the workloads that are rolling out, according to the configured mode (see common.RolloutsConfig):
+it waits for rollouts to complete (fetching the resources again), or scopes the resources of rolling out workloads out.
+If rollouts don't complete in time, the reason to abort the run is recorded for shouldAbort().
 
-return values:
-
-	shouldAbort bool - true if should abort
-	reason string    - reason for abortion
-	err error        - in case an error occurred
+It's called with runsMutex and runMutex held. It releases runMutex while waiting, so that waiting doesn't block calls other than runs
+(e.g. SetConfig(), Reload() or GetResourceIndex()), while runsMutex keeps other runs from changing the resources meanwhile.
 */
-func (v *Validation) shouldAbortValidation(ctx context.Context, client K8SProvider) (bool, string, error) {
-	configMap, err := client.ClientSet.CoreV1().ConfigMaps(v.AbortValidationConfigMapNamespace).Get(ctx, v.AbortValidationConfigMapName, metav1.GetOptions{})
-	if err != nil {
-		// if configMap not present, we perform validation anyway
-		if k8sErrors.IsNotFound(err) {
-			return false, fmt.Sprintf("Abort configMap %s not found: Resuming validation", v.AbortValidationConfigMapName), nil
-		}
-		return false, "", err
-	}
-
-	shouldAbort, ok := configMap.Data[v.AbortValidationConfigMapField]
-	if !ok {
-		// if field not present, we perform validation anyway
-		return false, fmt.Sprintf("Field %s not found in abort configMap: Resuming validation", v.AbortValidationConfigMapField), nil
+func (v *Validation) handleRollouts(additionalResourceTypes []schema.GroupVersionResource) error {
+	v.scopedOut, v.scopedOutResources = nil, nil
+	v.rolloutAbortReason = ""
+	detection := v.rollouts
+	if detection.mode == "" {
+		return nil
 	}
 
-	if string(shouldAbort) == "true" {
-		message := fmt.Sprintf("Abort configMap %s set to \"true\": Aborting validation", v.AbortValidationConfigMapName)
-		return true, message, nil
-	}
+	rollouts := detection.detect(v.Resources)
+	switch detection.mode {
+	case RolloutModeWait:
+		if len(rollouts) == 0 {
+			return nil
+		}
 
-	message := fmt.Sprintf("Abort configMap %s found, but field %s is NOT set to \"true\": Resuming validation", v.AbortValidationConfigMapName, v.AbortValidationConfigMapField)
-	return false, message, nil
-}
+		ctx, client, logger := v.ctx, *v.Client, v.logger
+		resources, index := v.Resources, v.resourceIndex
+		v.runMutex.Unlock()
+		deadline := time.Now().Add(detection.timeout)
+		for len(rollouts) > 0 && time.Now().Before(deadline) {
+			logger.V(1).Info(fmt.Sprintf("waiting for rollouts to complete: %s", describeRollouts(rollouts)))
+
+			timer := time.NewTimer(min(detection.pollInterval, time.Until(deadline)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				v.runMutex.Lock()
+				return ctx.Err()
+			case <-timer.C:
+			}
 
-func (v *Validation) loadConfiguration() {
-	k := koanf.New(".")
+			resources, index = fetchResources(ctx, client, additionalResourceTypes), nil
+			rollouts = detection.detect(resources)
+		}
+		v.runMutex.Lock()
 
-	content, err := afero.ReadFile(v.appFs, filepath.Join(resolveConfigDirectory(), configFileName))
-	if err != nil {
-		return
+		if index == nil {
+			index = v.newResourceIndex(resources)
+		}
+		v.Resources, v.resourceIndex = resources, index
+		if len(rollouts) > 0 {
+			v.rolloutAbortReason = detection.abortReason(rollouts)
+		}
+	case RolloutModeScopeOut:
+		if len(rollouts) > 0 {
+			for _, rollout := range rollouts {
+				v.logger.V(1).Info(fmt.Sprintf("scoping out the resources of %s", rollout))
+				v.scopedOut = append(v.scopedOut, rollout.resource.DeepCopy())
+			}
+			v.Resources, v.scopedOutResources = scopeOut(v.resourceIndex, rollouts)
+			v.resourceIndex = common.NewResourceIndex(v.Resources)
+		}
 	}
-	k.Load(rawbytes.Provider(content), koanfYaml.Parser())
+	return nil
+}
 
-	if k.String("exempt.labelName") != "" {
-		common.ExemptPodLabelName = k.String("exempt.labelName")
+/*
+abortConditions returns the custom abort conditions, if set, or else the configured ones
+*/
+func (v *Validation) abortConditions() AbortCondition {
+	if v.customAbortCondition != nil {
+		return v.customAbortCondition
 	}
-
-	if k.String("exempt.labelValue") != "" {
-		common.ExemptPodLabelValue = k.String("exempt.labelValue")
+	if v.abortCondition != nil {
+		return v.abortCondition
 	}
+	// the legacy fields may be set after NewValidation()
+	return configMapFieldCondition(common.ConfigMapFieldConfig{
+		Namespace: v.AbortValidationConfigMapNamespace,
+		Name:      v.AbortValidationConfigMapName,
+		Field:     v.AbortValidationConfigMapField,
+	})
+}
 
-	if k.String("abort.configMapName") != "" {
-		v.AbortValidationConfigMapName = k.String("abort.configMapName")
+/*
+loadConfiguration reads config.yaml, if present.
+If the CONFIG_STRICT environment variable is "true", a missing file, unknown keys and values of the wrong type are errors;
+otherwise, they are logged.
+*/
+func (v *Validation) loadConfiguration() error {
+	strict := os.Getenv(strictConfigEnv) == "true"
+	path := filepath.Join(resolveConfigDirectory(), configFileName)
+	v.configLoader = ConfigLoader{Files: []string{path}, Strict: strict}
+
+	content, err := afero.ReadFile(v.appFs, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !strict {
+			v.logger.V(0).Info("couldn't find configuration file", "path", path)
+			return nil
+		}
+		return fmt.Errorf("couldn't read %s: %w", path, err)
 	}
 
-	if k.String("abort.configMapNamespace") != "" {
-		v.AbortValidationConfigMapNamespace = k.String("abort.configMapNamespace")
+	// syntax errors are errors in either mode, like those of the layers of LoadConfig()
+	config, warnings, err := common.ParseConfig(content, strict)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	for _, warning := range warnings {
+		v.logger.Info(fmt.Sprintf("ignoring invalid configuration: %s: %s", path, warning))
 	}
 
-	if k.String("abort.configMapField") != "" {
-		v.AbortValidationConfigMapField = k.String("abort.configMapField")
+	if err = v.applyConfig(config); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
 	}
+	return nil
 }
@@ -3,12 +3,16 @@ package validation
 import (
 	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
+	"github.com/SAP/k8s-resource-validator/pkg/postprocessing"
 	"github.com/SAP/k8s-resource-validator/pkg/validators/fake"
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
@@ -17,7 +21,9 @@ import (
 	"github.com/tonglil/buflogr"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	testclient "k8s.io/client-go/dynamic/fake"
@@ -151,6 +157,52 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		Expect(out).To(ContainSubstring(resourceName))
 	})
 
+	It("write structured violation fields to log", func() {
+		resource := unstructured.Unstructured{}
+		resource.SetKind(common.KIND_POD)
+		resource.SetName("name")
+		violation := common.NewViolation(resource, "message", 0, "validator").
+			WithRuleID("privileged").
+			WithFieldPath("spec.containers[1].securityContext.privileged").
+			WithContainerName("sidecar").
+			WithRemediation("set privileged to false").
+			WithDetail("value", "true")
+
+		logLengthBefore := len(logBuffer.String())
+		err := LogViolations(ctx, []common.Violation{violation}, 0)
+		Expect(err).To(Succeed())
+
+		out := logBuffer.String()[logLengthBefore:]
+		Expect(out).To(ContainSubstring("rule: privileged"))
+		Expect(out).To(ContainSubstring("field: spec.containers[1].securityContext.privileged"))
+		Expect(out).To(ContainSubstring("container: sidecar"))
+		Expect(out).To(ContainSubstring("remediation: set privileged to false"))
+		Expect(out).To(ContainSubstring("details: value=true"))
+
+		report := NewViolationReport(violation)
+		Expect(report.FieldPath).To(Equal(violation.FieldPath))
+		Expect(report.Container).To(Equal("sidecar"))
+		Expect(report.Details).To(Equal(map[string]string{"value": "true"}))
+	})
+
+	It("write the violations of a result to log", func() {
+		resource := unstructured.Unstructured{}
+		resource.SetKind(common.KIND_POD)
+		resource.SetName("result resource")
+		violation := common.NewViolation(resource, "result message", common.SeverityCritical.Level(), "validator").WithRuleID("privileged")
+		result := NewResult(time.Now(), nil, nil, []common.Violation{violation}, nil)
+
+		logLengthBefore := len(logBuffer.String())
+		err := LogResultViolations(ctx, result, common.SeverityCritical)
+		Expect(err).To(Succeed())
+
+		out := logBuffer.String()[logLengthBefore:]
+		Expect(out).To(ContainSubstring("error violations"))
+		Expect(out).To(ContainSubstring("result resource"))
+		Expect(out).To(ContainSubstring("result message"))
+		Expect(out).To(ContainSubstring("rule: privileged"))
+	})
+
 	It("write to log with no violations", func() {
 		violations := []common.Violation{}
 
@@ -186,9 +238,18 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		validation.AbortValidationConfigMapNamespace = abortConfigMapNamespace
 		Expect(err).To(Succeed())
 
-		aborted, _ := validation.preValidate()
+		aborted, _, _ := validation.ShouldAbort()
 
 		Expect(aborted).To(BeTrue())
+
+		// the fields set by the caller survive a configuration, unless it sets them
+		config := common.NewConfig()
+		Expect(validation.SetConfig(config)).To(Succeed())
+		Expect(validation.AbortValidationConfigMapName).To(Equal(abortConfigMapName))
+		config.Abort.ConfigMapName = "configured"
+		Expect(validation.SetConfig(config)).To(Succeed())
+		Expect(validation.AbortValidationConfigMapName).To(Equal("configured"))
+		Expect(validation.AbortValidationConfigMapField).To(Equal(AbortValidationConfigMapField))
 	})
 
 	It("do not abort validation (configmap field is not true)", func() {
@@ -215,7 +276,7 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		validation.AbortValidationConfigMapNamespace = abortConfigMapNamespace
 		Expect(err).To(Succeed())
 
-		aborted, _ := validation.preValidate()
+		aborted, _, _ := validation.ShouldAbort()
 
 		Expect(aborted).To(BeFalse())
 	})
@@ -244,11 +305,331 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		validation.AbortValidationConfigMapNamespace = abortConfigMapNamespace
 		Expect(err).To(Succeed())
 
-		aborted, _ := validation.preValidate()
+		aborted, _, _ := validation.ShouldAbort()
 
 		Expect(aborted).To(BeFalse())
 	})
 
+	DescribeTable("abort conditions",
+		func(config common.AbortConditionConfig, expected bool, expectedMessage string) {
+			leaseDuration := int32(60)
+			holder := "deployer"
+			client := &K8SProvider{
+				ClientSet: k8sfake.NewSimpleClientset(
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "center", Name: "state"}, Data: map[string]string{"phase": "deploying"}},
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Annotations: map[string]string{"example.com/frozen": "yes"}}},
+					&coordinationv1.Lease{
+						ObjectMeta: metav1.ObjectMeta{Namespace: "center", Name: "deploying"},
+						Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &leaseDuration, RenewTime: &metav1.MicroTime{Time: time.Date(2024, time.March, 2, 22, 59, 30, 0, time.UTC)}},
+					},
+					&coordinationv1.Lease{
+						ObjectMeta: metav1.ObjectMeta{Namespace: "center", Name: "expired"},
+						Spec:       coordinationv1.LeaseSpec{LeaseDurationSeconds: &leaseDuration, RenewTime: &metav1.MicroTime{Time: time.Date(2024, time.March, 2, 20, 0, 0, 0, time.UTC)}},
+					},
+				),
+			}
+			resources := []unstructured.Unstructured{
+				{Object: map[string]interface{}{
+					"kind":     common.KIND_DEPLOYMENT,
+					"metadata": map[string]interface{}{"namespace": "apps", "name": "stable", "generation": int64(2)},
+					"spec":     map[string]interface{}{"replicas": int64(2)},
+					"status":   map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)},
+				}},
+				{Object: map[string]interface{}{
+					"kind":     common.KIND_STATEFUL_SET,
+					"metadata": map[string]interface{}{"namespace": "apps", "name": "db", "generation": int64(3)},
+					"spec":     map[string]interface{}{"replicas": int64(3)},
+					"status":   map[string]interface{}{"observedGeneration": int64(3), "updatedReplicas": int64(1), "readyReplicas": int64(3)},
+				}},
+			}
+
+			condition, err := NewAbortCondition(config)
+			Expect(err).To(Succeed())
+			// a Saturday, 23:00 UTC
+			abort, message, err := condition.ShouldAbort(ctx, AbortState{Client: client, Resources: resources, Now: time.Date(2024, time.March, 2, 23, 0, 0, 0, time.UTC)})
+			Expect(err).To(Succeed())
+			Expect(abort).To(Equal(expected))
+			Expect(message).To(ContainSubstring(expectedMessage))
+		},
+		Entry("configmap field", common.AbortConditionConfig{ConfigMap: &common.ConfigMapFieldConfig{Namespace: "center", Name: "state", Field: "phase", Value: "deploying"}}, true, `set to "deploying"`),
+		Entry("configmap field is not true", common.AbortConditionConfig{ConfigMap: &common.ConfigMapFieldConfig{Namespace: "center", Name: "state", Field: "phase"}}, false, `NOT set to "true"`),
+		Entry("namespace annotation", common.AbortConditionConfig{NamespaceAnnotation: &common.NamespaceAnnotationConfig{Namespace: "apps", Annotation: "example.com/frozen"}}, true, "example.com/frozen=yes"),
+		Entry("namespace annotation of another value", common.AbortConditionConfig{NamespaceAnnotation: &common.NamespaceAnnotationConfig{Namespace: "apps", Annotation: "example.com/frozen", Value: "no"}}, false, "NOT annotated"),
+		Entry("lease", common.AbortConditionConfig{Lease: &common.LeaseConfig{Namespace: "center", Name: "deploying"}}, true, "held by deployer"),
+		Entry("expired lease", common.AbortConditionConfig{Lease: &common.LeaseConfig{Namespace: "center", Name: "expired"}}, false, "expired"),
+		Entry("missing lease", common.AbortConditionConfig{Lease: &common.LeaseConfig{Namespace: "center", Name: "missing"}}, false, "not found"),
+		Entry("rollout", common.AbortConditionConfig{Rollout: &common.RolloutConfig{}}, true, "StatefulSet/apps/db is rolling out (1 of 3 replicas are updated)"),
+		Entry("rollout of other kinds", common.AbortConditionConfig{Rollout: &common.RolloutConfig{Kinds: []string{common.KIND_DEPLOYMENT}}}, false, "No Deployment is rolling out"),
+		Entry("maintenance window", common.AbortConditionConfig{MaintenanceWindow: &common.MaintenanceWindowConfig{Days: []string{"sat"}, Start: "22:00", End: "02:00"}}, true, "Within"),
+		Entry("maintenance window of another time zone", common.AbortConditionConfig{MaintenanceWindow: &common.MaintenanceWindowConfig{Days: []string{"Sat"}, Start: "22:00", End: "23:30", TimeZone: "Europe/Berlin"}}, false, "Outside"),
+		Entry("maintenance window that started the day before", common.AbortConditionConfig{MaintenanceWindow: &common.MaintenanceWindowConfig{Days: []string{"fri"}, Start: "20:00", End: "23:30"}}, false, "Outside"),
+	)
+
+	DescribeTable("invalid abort conditions",
+		func(config common.AbortConfig, expectedError string) {
+			_, err := NewAbortConditions(config)
+			Expect(err).To(MatchError(ContainSubstring(expectedError)))
+		},
+		Entry("no condition", common.AbortConfig{Conditions: []common.AbortConditionConfig{{}}}, "condition 1: expected exactly one"),
+		Entry("two conditions", common.AbortConfig{Conditions: []common.AbortConditionConfig{{Rollout: &common.RolloutConfig{}, Lease: &common.LeaseConfig{Namespace: "a", Name: "b"}}}}, "found 2"),
+		Entry("unknown combine", common.AbortConfig{Combine: "some", Conditions: []common.AbortConditionConfig{{Rollout: &common.RolloutConfig{}}}}, "unknown combine"),
+		Entry("unsupported kind", common.AbortConfig{Conditions: []common.AbortConditionConfig{{Rollout: &common.RolloutConfig{Kinds: []string{common.KIND_POD}}}}}, "unsupported kind Pod"),
+		Entry("invalid day", common.AbortConfig{Conditions: []common.AbortConditionConfig{{MaintenanceWindow: &common.MaintenanceWindowConfig{Days: []string{"monday"}, Start: "01:00", End: "02:00"}}}}, "unknown day"),
+		Entry("invalid time", common.AbortConfig{Conditions: []common.AbortConditionConfig{{MaintenanceWindow: &common.MaintenanceWindowConfig{Start: "1am", End: "02:00"}}}}, "invalid start"),
+		Entry("missing configmap field", common.AbortConfig{Conditions: []common.AbortConditionConfig{{ConfigMap: &common.ConfigMapFieldConfig{Namespace: "a", Name: "b"}}}}, "requires a namespace, name and field"),
+	)
+
+	It("combined abort conditions are recorded in the result", func() {
+		frozen := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Annotations: map[string]string{"example.com/frozen": "true"}}}
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(frozen),
+		}
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(client)
+
+		config := validation.GetConfig()
+		config.Abort.Combine = "all"
+		config.Abort.Conditions = []common.AbortConditionConfig{
+			{NamespaceAnnotation: &common.NamespaceAnnotationConfig{Namespace: "apps", Annotation: "example.com/frozen"}},
+			{ConfigMap: &common.ConfigMapFieldConfig{Namespace: "center", Name: "state", Field: "phase"}},
+		}
+		Expect(validation.SetConfig(config)).To(Succeed())
+		fakeValidator, err := fake.NewFakeValidator(ctx, 1, false)
+		Expect(err).To(Succeed())
+
+		result := validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.AbortReason).To(BeEmpty()) // the ConfigMap doesn't exist
+		Expect(result.Violations).To(HaveLen(1))
+
+		config.Abort.Combine = "any"
+		Expect(validation.SetConfig(config)).To(Succeed())
+		validation.Refresh()
+		result = validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.AbortReason).To(ContainSubstring("Namespace apps is annotated with example.com/frozen=true"))
+		Expect(result.Violations).To(BeEmpty())
+
+		validation.SetAbortConditions(AbortConditionFunc(func(context.Context, AbortState) (bool, string, error) {
+			return false, "custom", nil
+		}))
+		result = validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.AbortReason).To(BeEmpty())
+		Expect(result.Violations).To(HaveLen(1))
+	})
+
+	It("aborted runs are reported as a distinct outcome", func() {
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(client)
+		fakeValidator, err := fake.NewFakeValidator(ctx, 1, false)
+		Expect(err).To(Succeed())
+		validators := []common.Validator{fakeValidator}
+
+		abort := true
+		validation.SetAbortConditions(AbortConditionFunc(func(context.Context, AbortState) (bool, string, error) {
+			return abort, "", nil
+		}))
+
+		// Validate() keeps its contract: an aborted run returns neither violations nor an error
+		violations, err := validation.Validate(validators)
+		Expect(err).To(Succeed())
+		Expect(violations).To(BeEmpty())
+
+		result := validation.ValidateWithResult(validators, nil)
+		Expect(result.Outcome).To(Equal(OutcomeAborted))
+		Expect(result.AbortReason).To(Equal("an abort condition was met"))
+		Expect(result.Errors).To(BeEmpty())
+
+		abort = false
+		result = validation.ValidateWithResult(validators, nil)
+		Expect(result.Outcome).To(Equal(OutcomeCompleted))
+		Expect(result.Violations).To(HaveLen(1))
+		Expect(NewResult(time.Now(), validators, nil, nil, errors.New("failed")).Outcome).To(Equal(OutcomeFailed))
+
+		logBuffer.Reset()
+		Expect(LogOutcome(ctx, &Result{Outcome: OutcomeAborted, AbortReason: "frozen"})).To(Succeed())
+		Expect(logBuffer.String()).To(ContainSubstring("validation run was aborted: frozen"))
+	})
+
+	DescribeTable("rollout detection",
+		func(kind string, spec map[string]interface{}, status map[string]interface{}, expected bool, expectedReason string) {
+			resource := &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind":     kind,
+				"metadata": map[string]interface{}{"namespace": "apps", "name": "app", "generation": int64(2)},
+				"spec":     spec,
+				"status":   status,
+			}}
+			rollingOut, reason := isRollingOut(resource)
+			Expect(rollingOut).To(Equal(expected))
+			Expect(reason).To(Equal(expectedReason))
+		},
+		Entry("new generation", common.KIND_DEPLOYMENT, map[string]interface{}{}, map[string]interface{}{"observedGeneration": int64(1)}, true, "generation 2 is not observed yet"),
+		Entry("complete deployment", common.KIND_DEPLOYMENT, map[string]interface{}{"replicas": int64(2)},
+			map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)}, false, ""),
+		Entry("deployment with old replicas", common.KIND_DEPLOYMENT, map[string]interface{}{"replicas": int64(2)},
+			map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2)}, true, "1 old replicas are pending termination"),
+		Entry("statefulset with a new revision", common.KIND_STATEFUL_SET, map[string]interface{}{"replicas": int64(1)},
+			map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(1), "readyReplicas": int64(1), "currentRevision": "a", "updateRevision": "b"}, true, "revision b is not current yet"),
+		Entry("daemonset", common.KIND_DAEMON_SET, map[string]interface{}{},
+			map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(2), "numberAvailable": int64(3)}, true, "2 of 3 scheduled pods are updated"),
+		Entry("complete daemonset", common.KIND_DAEMON_SET, map[string]interface{}{},
+			map[string]interface{}{"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(3)}, false, ""),
+		Entry("scaling replicaset", common.KIND_REPLICA_SET, map[string]interface{}{"replicas": int64(0)},
+			map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(2)}, true, "scaling from 2 to 0 replicas"),
+	)
+
+	Describe("rollouts", func() {
+		var client *K8SProvider
+		var deployment *appsv1.Deployment
+		deploymentsResource := appsv1.SchemeGroupVersion.WithResource("deployments")
+
+		BeforeEach(func() {
+			replicas := int32(2)
+			deployment = &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app", UID: "deployment", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+			}
+			replicaSet := &appsv1.ReplicaSet{
+				TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_REPLICA_SET},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app-1", UID: "replicaset",
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT, Name: "app", UID: "deployment"}}},
+				Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+				Status: appsv1.ReplicaSetStatus{Replicas: 2, AvailableReplicas: 2},
+			}
+			rolloutPod := &corev1.Pod{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app-1-a",
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_REPLICA_SET, Name: "app-1", UID: "replicaset"}}},
+			}
+			stablePod := &corev1.Pod{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "stable"},
+			}
+			client = &K8SProvider{
+				Dynamic:   testclient.NewSimpleDynamicClient(scheme, deployment, replicaSet, rolloutPod, stablePod),
+				ClientSet: k8sfake.NewSimpleClientset(),
+			}
+		})
+
+		validate := func(rollouts common.RolloutsConfig) *Result {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			validation.SetClient(client)
+			config := validation.GetConfig()
+			config.Rollouts = rollouts
+			Expect(validation.SetConfig(config)).To(Succeed())
+			return validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+		}
+
+		It("abort by a rollout abort condition", func() {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			validation.SetClient(client)
+			config := validation.GetConfig()
+			config.Abort.Conditions = []common.AbortConditionConfig{{Rollout: &common.RolloutConfig{}}}
+			Expect(validation.SetConfig(config)).To(Succeed())
+			result := validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+			Expect(result.AbortReason).To(Equal("Deployment.apps/apps/app is rolling out (1 of 2 replicas are updated): Aborting validation"))
+			Expect(result.Violations).To(BeEmpty())
+		})
+
+		It("abort mode is replaced by the rollout abort condition", func() {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			config := validation.GetConfig()
+			config.Rollouts.Mode = "abort"
+			Expect(validation.SetConfig(config)).To(MatchError(ContainSubstring("use a rollout abort condition")))
+		})
+
+		It("scope out", func() {
+			result := validate(common.RolloutsConfig{Mode: RolloutModeScopeOut})
+			Expect(result.AbortReason).To(BeEmpty())
+			Expect(result.ScopedOut).To(ConsistOf(common.ViolationTarget{Kind: common.KIND_DEPLOYMENT, Group: "apps", Namespace: "apps", Name: "app"}))
+			Expect(result.Violations).To(HaveLen(1))
+			Expect(result.Violations[0].Resource.Name).To(Equal("stable"))
+		})
+
+		It("wait until the rollout didn't complete in time", func() {
+			result := validate(common.RolloutsConfig{Mode: RolloutModeWait, Timeout: 30 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+			Expect(result.AbortReason).To(ContainSubstring("didn't complete within 30ms"))
+			Expect(result.Violations).To(BeEmpty())
+		})
+
+		It("waiting doesn't block other calls", func() {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			validation.SetClient(client)
+			config := validation.GetConfig()
+			config.Rollouts = common.RolloutsConfig{Mode: RolloutModeWait, Timeout: 500 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+			Expect(validation.SetConfig(config)).To(Succeed())
+
+			done := make(chan *Result)
+			go func() {
+				defer GinkgoRecover()
+				done <- validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			start := time.Now()
+			Expect(validation.SetConfig(config)).To(Succeed())
+			Expect(validation.GetResourceIndex()).NotTo(BeNil())
+			Expect(time.Since(start)).To(BeNumerically("<", 200*time.Millisecond))
+			Expect((<-done).AbortReason).To(ContainSubstring("didn't complete within 500ms"))
+		})
+
+		It("runs are serialized while waiting", func() {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			validation.SetClient(client)
+			config := validation.GetConfig()
+			config.Rollouts = common.RolloutsConfig{Mode: RolloutModeWait, Timeout: 500 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+			Expect(validation.SetConfig(config)).To(Succeed())
+
+			done := make(chan *Result, 1)
+			go func() {
+				defer GinkgoRecover()
+				done <- validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			start := time.Now()
+			validation.Refresh() // doesn't discard the resources of the waiting run
+			Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+			Eventually(done).Should(Receive(HaveField("AbortReason", ContainSubstring("didn't complete within 500ms"))))
+		})
+
+		It("wait until the rollout completed", func() {
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(20 * time.Millisecond)
+				deployment.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+				object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+				Expect(err).To(Succeed())
+				_, err = client.Dynamic.Resource(deploymentsResource).Namespace("apps").Update(ctx, &unstructured.Unstructured{Object: object}, metav1.UpdateOptions{})
+				Expect(err).To(Succeed())
+			}()
+
+			result := validate(common.RolloutsConfig{Mode: RolloutModeWait, Timeout: 5 * time.Second, PollInterval: 10 * time.Millisecond})
+			Expect(result.AbortReason).To(BeEmpty())
+			Expect(result.Violations).To(HaveLen(2))
+		})
+
+		It("invalid mode", func() {
+			validation, err := NewValidation(ctx)
+			Expect(err).To(Succeed())
+			config := validation.GetConfig()
+			config.Rollouts.Mode = "pause"
+			Expect(validation.SetConfig(config)).To(MatchError(ContainSubstring("invalid rollouts: unknown mode")))
+		})
+	})
+
 	It("additional resource types file parsing error", func() {
 		additionalResourceTyepeAsString := "-- "
 		_ = appFs.MkdirAll(configDirectory, 0755)
@@ -285,6 +666,522 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		Expect(grvs).To(BeNil())
 	})
 
+	It("result history retains the most recent results", func() {
+		history := NewResultHistory(2)
+		Expect(history.Last()).To(BeNil())
+
+		first := &Result{Outcome: OutcomeFailed, Errors: []string{"1"}}
+		second := &Result{Outcome: OutcomeFailed, Errors: []string{"2"}}
+		third := &Result{Outcome: OutcomeFailed, Errors: []string{"3"}}
+		history.Add(first)
+		history.Add(second)
+		history.Add(third)
+
+		Expect(history.All()).To(Equal([]*Result{second, third}))
+		Expect(history.Last()).To(Equal(third))
+		Expect(history.Outcomes()).To(Equal(map[Outcome]int64{OutcomeCompleted: 0, OutcomeAborted: 0, OutcomeFailed: 3}))
+
+		history.Add(&Result{Outcome: OutcomeAborted})
+		history.Add(&Result{Outcome: OutcomeCompleted})
+		history.Add(&Result{Outcome: OutcomeAborted})
+		Expect(history.Outcomes()).To(Equal(map[Outcome]int64{OutcomeCompleted: 1, OutcomeAborted: 2, OutcomeFailed: 3}))
+	})
+
+	It("refresh causes resources to be fetched again", func() {
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+
+		validation, err := NewValidation(ctx)
+		validation.SetClient(client)
+		Expect(err).To(Succeed())
+
+		_, err = validation.Validate(nil)
+		Expect(err).To(Succeed())
+		Expect(validation.PreValidated).To(BeTrue())
+
+		validation.Refresh()
+		Expect(validation.PreValidated).To(BeFalse())
+	})
+
+	It("run phases can be invoked separately, and share the collected resources", func() {
+		podsResource := corev1.SchemeGroupVersion.WithResource("pods")
+		pod := func(name string) *unstructured.Unstructured {
+			object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.Pod{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			})
+			Expect(err).To(Succeed())
+			return &unstructured.Unstructured{Object: object}
+		}
+		dynamicClient := testclient.NewSimpleDynamicClient(scheme, pod("a"))
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{Dynamic: dynamicClient, ClientSet: k8sfake.NewSimpleClientset()})
+		validators := []common.Validator{&perResourceValidator{kind: common.KIND_POD}}
+
+		// a custom abort function doesn't affect the caching of the collected resources
+		abortCalls := 0
+		validation.SetAbortFunc(func() (bool, error) {
+			abortCalls++
+			return false, nil
+		})
+
+		Expect(validation.Collect()).To(Succeed())
+		Expect(validation.PreValidated).To(BeTrue())
+		_, err = dynamicClient.Resource(podsResource).Namespace("default").Create(ctx, pod("b"), metav1.CreateOptions{})
+		Expect(err).To(Succeed())
+
+		aborted, _, err := validation.ShouldAbort()
+		Expect(err).To(Succeed())
+		Expect(aborted).To(BeFalse())
+		violations, err := validation.Evaluate(validators)
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(1)) // pod b wasn't collected yet
+		violations, err = validation.Validate(validators)
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(1))
+		Expect(abortCalls).To(Equal(2)) // the abort conditions are evaluated by each run, though
+
+		validation.Refresh()
+		violations, err = validation.Evaluate(validators)
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(2))
+
+		_, err = dynamicClient.Resource(podsResource).Namespace("default").Create(ctx, pod("c"), metav1.CreateOptions{})
+		Expect(err).To(Succeed())
+		Expect(validation.ValidateWithResult(validators, nil).Violations).To(HaveLen(2))
+		Expect(validation.RefreshAndValidateWithResult(validators, nil).Violations).To(HaveLen(3))
+	})
+
+	It("diff saved results", func() {
+		resource := unstructured.Unstructured{}
+		resource.SetKind(common.KIND_POD)
+		resource.SetName("a")
+		violationA := common.NewViolation(resource, "message", 1, "validator")
+		resource = unstructured.Unstructured{}
+		resource.SetKind(common.KIND_POD)
+		resource.SetName("b")
+		violationB := common.NewViolation(resource, "message", 1, "validator")
+
+		previous := NewResult(time.Now(), nil, nil, []common.Violation{violationA}, nil)
+		current := NewResult(time.Now(), nil, nil, []common.Violation{violationA, violationB}, nil)
+		// reports saved by earlier versions don't carry fingerprints
+		previous.Violations[0].Fingerprint = ""
+
+		_ = appFs.MkdirAll("/reports", 0755)
+		Expect(WriteResult(appFs, "/reports/previous.json", previous)).To(Succeed())
+		Expect(WriteResult(appFs, "/reports/current.json", current)).To(Succeed())
+
+		diff, err := DiffResultFiles(appFs, "/reports/previous.json", "/reports/current.json")
+		Expect(err).To(Succeed())
+		Expect(diff.Added).To(HaveLen(1))
+		Expect(diff.Added[0].Resource.Name).To(Equal("b"))
+		Expect(diff.Resolved).To(BeEmpty())
+		Expect(diff.Unchanged).To(HaveLen(1))
+
+		_, err = DiffResultFiles(appFs, "/reports/previous.json", "/reports/missing.json")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("baseline suppresses known violations until they expire", func() {
+		fakeValidator, err := fake.NewFakeValidator(ctx, 3, false)
+		Expect(err).To(Succeed())
+		knownViolations, err := fakeValidator.Validate(nil)
+		Expect(err).To(Succeed())
+
+		yesterday := time.Now().Add(-24 * time.Hour)
+		baseline := append(NewBaseline(knownViolations[:1], nil), NewBaseline(knownViolations[1:2], &yesterday)...)
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		Expect(WriteBaseline(appFs, filepath.Join(configDirectory, baselineFile), baseline)).To(Succeed())
+
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+
+		validation, err := NewValidation(ctx)
+		validation.SetClient(client)
+		Expect(err).To(Succeed())
+
+		violations, err := validation.Validate([]common.Validator{fakeValidator})
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(2))
+		Expect(violations[0].Resource.GetName()).To(Equal("1"))
+
+		result := validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.Violations).To(HaveLen(2))
+		Expect(result.Suppressed).To(Equal(1))
+	})
+
+	It("baseline is applied before post-processors", func() {
+		fakeValidator, err := fake.NewFakeValidator(ctx, 3, false)
+		Expect(err).To(Succeed())
+		knownViolations, err := fakeValidator.Validate(nil)
+		Expect(err).To(Succeed())
+
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(client)
+		validation.SetBaseline(NewBaseline(knownViolations[:2], nil))
+		validation.SetPostProcessors(postprocessing.NewLimit(1))
+
+		// the limit doesn't keep a suppressed violation instead of the new one
+		result := validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.Suppressed).To(Equal(2))
+		Expect(result.Violations).To(HaveLen(1))
+		Expect(result.Violations[0].Fingerprint).To(Equal(knownViolations[2].Fingerprint()))
+	})
+
+	It("baseline generated from a result suppresses violations that are merged", func() {
+		fakeValidator, err := fake.NewFakeValidator(ctx, 2, false)
+		Expect(err).To(Succeed())
+
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(client)
+		validation.SetPostProcessors(postprocessing.NewMerge())
+
+		// each resource has two violations, which are merged
+		validators := []common.Validator{fakeValidator, fakeValidator}
+		result := validation.ValidateWithResult(validators, nil)
+		Expect(result.Violations).To(HaveLen(2))
+		Expect(result.BaselineCandidates).To(HaveLen(4))
+
+		validation.SetBaseline(NewBaselineFromResult(result, nil))
+		result = validation.ValidateWithResult(validators, nil)
+		Expect(result.Violations).To(BeEmpty())
+		Expect(result.Suppressed).To(Equal(4))
+		Expect(result.BaselineCandidates).To(HaveLen(4))
+	})
+
+	It("baseline generated from a result", func() {
+		fakeValidator, err := fake.NewFakeValidator(ctx, 2, false)
+		Expect(err).To(Succeed())
+		violations, err := fakeValidator.Validate(nil)
+		Expect(err).To(Succeed())
+
+		baseline := NewBaselineFromResult(NewResult(time.Now(), nil, nil, violations, nil), nil)
+		_ = appFs.MkdirAll("/baseline", 0755)
+		Expect(WriteBaseline(appFs, "/baseline/baseline.yaml", baseline)).To(Succeed())
+
+		readBaseline, err := ReadBaseline(appFs, "/baseline/baseline.yaml")
+		Expect(err).To(Succeed())
+		Expect(readBaseline).To(HaveLen(2))
+
+		reported, suppressed := readBaseline.Apply(violations, time.Now())
+		Expect(reported).To(BeEmpty())
+		Expect(suppressed).To(HaveLen(2))
+	})
+
+	It("baseline file parsing error", func() {
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, baselineFile), []byte("- message: no fingerprint\n"), 0644)
+
+		client := &K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		}
+
+		validation, err := NewValidation(ctx)
+		validation.SetClient(client)
+		Expect(err).To(Succeed())
+
+		_, err = validation.Validate(nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("severity overrides from configuration", func() {
+		configAsString := `severities:
+  - validator: built-in:fake
+    severity: info
+  - validator: built-in:fake
+    rule: fake
+    severity: critical
+  - validator: other
+    severity: low
+`
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+
+		fakeValidator, err := fake.NewFakeValidator(ctx, 1, false)
+		Expect(err).To(Succeed())
+
+		violations, err := validation.Validate([]common.Validator{fakeValidator})
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(1))
+		Expect(violations[0].Severity()).To(Equal(common.SeverityCritical)) // the rule's override takes precedence
+
+		Expect(validation.SetSeverityOverrides(SeverityOverrides{{Validator: fake.ValidatorName, Severity: "INFO"}})).To(Succeed())
+		result := validation.ValidateWithResult([]common.Validator{fakeValidator}, nil)
+		Expect(result.Violations[0].Severity).To(Equal(common.SeverityInfo))
+		Expect(result.Violations[0].Level).To(Equal(4))
+	})
+
+	It("post-processors from configuration", func() {
+		configAsString := `postProcessors:
+  - type: filter
+    match:
+      namespaces: ["fake"]
+    onlyIfSole: false
+  - type: limit
+    max: 1
+`
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+
+		fakeValidator, err := fake.NewFakeValidator(ctx, 2, false)
+		Expect(err).To(Succeed())
+
+		violations, err := validation.Validate([]common.Validator{fakeValidator})
+		Expect(err).To(Succeed())
+		Expect(violations).To(BeEmpty())
+
+		validation.SetPostProcessors(postprocessing.NewLimit(1))
+		violations, err = validation.Validate([]common.Validator{fakeValidator})
+		Expect(err).To(Succeed())
+		Expect(violations).To(HaveLen(1))
+	})
+
+	It("invalid post-processors in configuration", func() {
+		configAsString := "postProcessors:\n  - type: unknown\n"
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		_, err := NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring("postProcessors")))
+	})
+
+	It("invalid severity in configuration", func() {
+		configAsString := "severities:\n  - validator: built-in:fake\n    severity: urgent\n"
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		_, err := NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring("urgent")))
+	})
+
+	It("resource index is provided to validators", func() {
+		replicaSet := &unstructured.Unstructured{}
+		replicaSet.SetAPIVersion("apps/v1")
+		replicaSet.SetKind(common.KIND_REPLICA_SET)
+		replicaSet.SetName("replicaset")
+		replicaSet.SetNamespace("namespace")
+		replicaSet.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_REPLICA_SET, Name: "missing"}})
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme, replicaSet),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+
+		logLengthBefore := len(logBuffer.String())
+		validator := &indexAwareValidator{}
+		_, err = validation.Validate([]common.Validator{validator})
+		Expect(err).To(Succeed())
+
+		Expect(validator.index).NotTo(BeNil())
+		Expect(validator.index).To(BeIdenticalTo(validation.GetResourceIndex()))
+		Expect(validator.index.DanglingOwnerReferences()).To(HaveLen(1))
+		Expect(logBuffer.String()[logLengthBefore:]).To(ContainSubstring("dangling owner reference"))
+	})
+
+	It("annotations exempt resources and their dependents", func() {
+		newResource := func(apiVersion string, kind string, name string, owner *unstructured.Unstructured, annotations map[string]string) *unstructured.Unstructured {
+			resource := &unstructured.Unstructured{}
+			resource.SetAPIVersion(apiVersion)
+			resource.SetKind(kind)
+			resource.SetName(name)
+			resource.SetNamespace("namespace")
+			resource.SetAnnotations(annotations)
+			if owner != nil {
+				resource.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: owner.GetAPIVersion(), Kind: owner.GetKind(), Name: owner.GetName()}})
+			}
+			return resource
+		}
+
+		tomorrow := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+		deployment := newResource("apps/v1", common.KIND_DEPLOYMENT, "exempt", nil, map[string]string{
+			ExemptionAnnotation:              "other, per-resource/per-resource",
+			ExemptionJustificationAnnotation: "needed by the legacy agent",
+			ExemptionExpiresAnnotation:       tomorrow,
+		})
+		replicaSet := newResource("apps/v1", common.KIND_REPLICA_SET, "exempt-1", deployment, nil)
+		exemptPod := newResource("v1", common.KIND_POD, "exempt-1-a", replicaSet, nil)
+		otherRulePod := newResource("v1", common.KIND_POD, "other-rule", nil, map[string]string{
+			ExemptionAnnotation:              "per-resource/other-rule",
+			ExemptionJustificationAnnotation: "only another rule is exempt",
+		})
+		expiredPod := newResource("v1", common.KIND_POD, "expired", nil, map[string]string{
+			ExemptionAnnotation:              "*",
+			ExemptionJustificationAnnotation: "temporary",
+			ExemptionExpiresAnnotation:       "2020-01-01",
+		})
+		unjustifiedPod := newResource("v1", common.KIND_POD, "unjustified", nil, map[string]string{ExemptionAnnotation: "*"})
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme, deployment, replicaSet, exemptPod, otherRulePod, expiredPod, unjustifiedPod),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+
+		validator := &perResourceValidator{kind: common.KIND_POD}
+		result := validation.ValidateWithResult([]common.Validator{validator}, nil)
+		Expect(result.Errors).To(BeEmpty())
+
+		reported := map[string][]string{}
+		for _, violation := range result.Violations {
+			reported[violation.Resource.Name] = append(reported[violation.Resource.Name], violation.Validator+"/"+violation.Rule)
+		}
+		Expect(reported).To(Equal(map[string][]string{
+			"other-rule":  {"per-resource/per-resource"},
+			"expired":     {"per-resource/per-resource", ExemptionsValidatorName + "/" + ruleExpiredExemption},
+			"unjustified": {"per-resource/per-resource", ExemptionsValidatorName + "/" + ruleMissingJustification},
+		}))
+
+		Expect(result.Exempted).To(HaveLen(1))
+		Expect(result.Exempted[0].Resource.Name).To(Equal("exempt-1-a"))
+		Expect(result.Exempted[0].ExemptedBy.Kind).To(Equal(common.KIND_DEPLOYMENT))
+		Expect(result.Exempted[0].Justification).To(Equal("needed by the legacy agent"))
+		Expect(result.Exempted[0].Expires).NotTo(BeNil())
+
+		Expect(validation.ValidateWithResult([]common.Validator{validator}, []string{"other"}).Exempted).To(BeEmpty())
+	})
+
+	It("label selectors exempt resources and namespaces", func() {
+		configAsString := `exempt:
+  selectors: ["app in (agent, proxy), !monitored"]
+  namespaceSelectors: ["team=infra"]
+  validators:
+    - validator: other
+      selectors: ["tier=batch"]
+    - validator: per-resource
+      namespaceSelectors: ["stage=dev"]
+`
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		newPod := func(name string, namespace string, labels map[string]string) *unstructured.Unstructured {
+			pod := &unstructured.Unstructured{}
+			pod.SetAPIVersion("v1")
+			pod.SetKind(common.KIND_POD)
+			pod.SetName(name)
+			pod.SetNamespace(namespace)
+			pod.SetLabels(labels)
+			return pod
+		}
+		newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+		}
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		validation.SetClient(&K8SProvider{
+			Dynamic: testclient.NewSimpleDynamicClient(scheme,
+				newPod("agent", "apps", map[string]string{"app": "agent"}),
+				newPod("monitored-agent", "apps", map[string]string{"app": "agent", "monitored": "true"}),
+				newPod("gardener", "apps", map[string]string{"resources.gardener.cloud/managed-by": "gardener"}),
+				newPod("batch", "apps", map[string]string{"tier": "batch"}),
+				newPod("infra", "infra", nil),
+				newPod("dev", "dev", nil),
+			),
+			ClientSet: k8sfake.NewSimpleClientset(
+				newNamespace("apps", nil),
+				newNamespace("infra", map[string]string{"team": "infra"}),
+				newNamespace("dev", map[string]string{"stage": "dev"}),
+			),
+		})
+
+		result := validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+		Expect(result.Errors).To(BeEmpty())
+
+		var reported []string
+		for _, violation := range result.Violations {
+			reported = append(reported, violation.Resource.Name)
+		}
+		Expect(reported).To(ConsistOf("monitored-agent", "batch"))
+
+		exemptedBy := map[string]string{}
+		for _, exempted := range result.Exempted {
+			exemptedBy[exempted.Resource.Name] = exempted.ExemptedBy.Kind + "/" + exempted.ExemptedBy.Name
+		}
+		Expect(exemptedBy).To(Equal(map[string]string{
+			"agent":    "Pod/agent",
+			"gardener": "Pod/gardener",
+			"infra":    "Namespace/infra",
+			"dev":      "Namespace/dev",
+		}))
+
+		Expect(validation.SetExemptions(common.ExemptionConfig{})).To(Succeed())
+		result = validation.ValidateWithResult([]common.Validator{&perResourceValidator{kind: common.KIND_POD}}, nil)
+		Expect(result.Violations).To(HaveLen(6))
+	})
+
+	It("invalid exemptions in configuration", func() {
+		configAsString := "exempt:\n  selectors: [\"app in agent\"]\n"
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		_, err := NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring("app in agent")))
+
+		validation := &Validation{}
+		Expect(validation.SetExemptions(common.ExemptionConfig{Validators: []common.ValidatorExemption{{Selectors: []string{"tier=batch"}}}})).NotTo(Succeed())
+	})
+
+	DescribeTable("exemption expiry", func(expires string, expectedViolation string) {
+		pod := unstructured.Unstructured{}
+		pod.SetKind(common.KIND_POD)
+		pod.SetName("pod")
+		pod.SetAnnotations(map[string]string{
+			ExemptionAnnotation:              "*",
+			ExemptionJustificationAnnotation: "justified",
+			ExemptionExpiresAnnotation:       expires,
+		})
+
+		now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		annotated, violations := readExemptions([]unstructured.Unstructured{pod}, now)
+		if expectedViolation == "" {
+			Expect(annotated).To(HaveLen(1))
+			Expect(violations).To(BeEmpty())
+		} else {
+			Expect(annotated).To(BeEmpty())
+			Expect(violations).To(HaveLen(1))
+			Expect(violations[0].RuleID).To(Equal(expectedViolation))
+		}
+	},
+		Entry("future date", "2024-06-02", ""),
+		Entry("future timestamp", "2024-06-01T13:00:00Z", ""),
+		Entry("the date has begun", "2024-06-01", ruleExpiredExemption),
+		Entry("past timestamp", "2024-06-01T11:00:00Z", ruleExpiredExemption),
+		Entry("invalid", "next week", ruleInvalidExemptionExpiry),
+	)
+
 	It("load configuration", func() {
 		a := "abort-ns1"
 		b := "abort-n1"
@@ -305,12 +1202,241 @@ var _ = Describe("k8s-resource-validator tests", func() {
 		validation.SetClient(client)
 		Expect(err).To(Succeed())
 
-		validation.loadConfiguration()
-
 		Expect(validation.AbortValidationConfigMapNamespace).To(Equal(a))
 		Expect(validation.AbortValidationConfigMapName).To(Equal(b))
 		Expect(validation.AbortValidationConfigMapField).To(Equal(c))
-		Expect(common.ExemptPodLabelName).To(Equal(d))
-		Expect(common.ExemptPodLabelValue).To(Equal(e))
+		Expect(validation.GetConfig().Exempt.LabelName).To(Equal(d))
+		Expect(validation.GetConfig().Exempt.LabelValue).To(Equal(e))
+	})
+
+	It("strict configuration", func() {
+		os.Setenv(strictConfigEnv, "true")
+		defer os.Unsetenv(strictConfigEnv)
+
+		_, err := NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring(configFileName))) // missing
+
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte("abort:\n  configMapNme: typo\n"), 0644)
+		_, err = NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring("line 2: field configMapNme not found")))
+
+		os.Unsetenv(strictConfigEnv)
+		logLengthBefore := len(logBuffer.String())
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		Expect(validation.GetConfig().Abort).To(Equal(common.NewConfig().Abort))
+		Expect(logBuffer.String()[logLengthBefore:]).To(ContainSubstring("configMapNme"))
+	})
+
+	It("syntax errors of the configuration are errors in either mode", func() {
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte("abort: [\n"), 0644)
+
+		_, err := NewValidation(ctx)
+		Expect(err).To(MatchError(ContainSubstring("invalid " + filepath.Join(configDirectory, configFileName))))
+
+		_, err = ConfigLoader{Files: []string{filepath.Join(configDirectory, configFileName)}}.Load(ctx, appFs, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("the sample configuration is valid in strict mode", func() {
+		content, err := os.ReadFile("../../hack/config.yaml")
+		Expect(err).To(Succeed())
+		config, _, err := common.ParseConfig(content, true)
+		Expect(err).To(Succeed())
+		Expect((&Validation{}).applyConfig(config)).To(Succeed())
+	})
+
+	It("each validation has its own configuration", func() {
+		configAsString := "exempt:\n  labelName: \"label1\"\nfreshness:\n  thresholdInHours: 3\n"
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, filepath.Join(configDirectory, configFileName), []byte(configAsString), 0644)
+
+		configured, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+
+		_ = appFs.Remove(filepath.Join(configDirectory, configFileName))
+		defaults, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+
+		Expect(configured.GetConfig().Exempt.LabelName).To(Equal("label1"))
+		Expect(configured.GetConfig().Exempt.LabelValue).To(Equal(common.NewConfig().Exempt.LabelValue))
+		Expect(configured.AbortValidationConfigMapName).To(Equal(common.NewConfig().Abort.ConfigMapName))
+		Expect(defaults.GetConfig().Exempt).To(Equal(common.NewConfig().Exempt))
+
+		configured.SetClient(&K8SProvider{
+			Dynamic:   testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(),
+		})
+		validator := &configAwareValidator{}
+		_, err = configured.Validate([]common.Validator{validator})
+		Expect(err).To(Succeed())
+
+		var freshness struct {
+			ThresholdInHours int `yaml:"thresholdInHours"`
+		}
+		found, err := validator.config.Section("freshness", &freshness)
+		Expect(err).To(Succeed())
+		Expect(found).To(BeTrue())
+		Expect(freshness.ThresholdInHours).To(Equal(3))
+
+		found, err = validator.config.Section("missing", &freshness)
+		Expect(err).To(Succeed())
+		Expect(found).To(BeFalse())
+	})
+
+	It("layered configuration", func() {
+		_ = appFs.MkdirAll(configDirectory, 0755)
+		_ = afero.WriteFile(appFs, "/base.yaml", []byte("abort:\n  configMapName: base\n  configMapField: base\nfreshness:\n  thresholdInHours: 1\n"), 0644)
+		_ = afero.WriteFile(appFs, "/override.yaml", []byte("abort:\n  configMapName: override\n"), 0644)
+		client := &K8SProvider{
+			Dynamic: testclient.NewSimpleDynamicClient(scheme),
+			ClientSet: k8sfake.NewSimpleClientset(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "center", Name: "validator"},
+					Data:       map[string]string{"config.yaml": "freshness:\n  thresholdInHours: 2\n"},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "center", Name: "validator"},
+					Data:       map[string][]byte{"webhooks": []byte("notifications:\n  webhooks:\n    - url: https://example.com/token\n")},
+				},
+			),
+		}
+		os.Setenv("TEST_ABORT_CONFIGMAPNAMESPACE", "environment")
+		defer os.Unsetenv("TEST_ABORT_CONFIGMAPNAMESPACE")
+
+		loader := ConfigLoader{EnvPrefix: "TEST_", Strict: true}
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		loader.RegisterFlags(flags)
+		Expect(flags.Parse([]string{
+			"--config", "/base.yaml", "--config", "/override.yaml",
+			"--config-map", "center/validator",
+			"--config-secret", "center/validator/webhooks",
+			"--set", "exempt.selectors=[app=agent]",
+			"--set", "abort.configMapField=flag",
+		})).To(Succeed())
+
+		loaded, err := loader.Load(ctx, appFs, client)
+		Expect(err).To(Succeed())
+		Expect(loaded.Warnings).To(BeEmpty())
+		Expect(loaded.Config.Abort).To(Equal(common.AbortConfig{ConfigMapNamespace: "environment", ConfigMapName: "override", ConfigMapField: "flag"}))
+		Expect(loaded.Config.Freshness.ThresholdInHours).To(Equal(int32(2)))
+		Expect(loaded.Config.Exempt.Selectors).To(Equal([]string{"app=agent"}))
+		Expect(loaded.Config.Exempt.LabelName).To(Equal(common.NewConfig().Exempt.LabelName))
+		Expect(loaded.Config.Notifications.Webhooks[0].URL).To(Equal("https://example.com/token"))
+
+		Expect(loaded.Origins).To(HaveKeyWithValue("abort.configMapNamespace", "environment variable TEST_ABORT_CONFIGMAPNAMESPACE"))
+		Expect(loaded.Origins).To(HaveKeyWithValue("abort.configMapName", "file /override.yaml"))
+		Expect(loaded.Origins).To(HaveKeyWithValue("abort.configMapField", "flag --set abort.configMapField"))
+		Expect(loaded.Origins).To(HaveKeyWithValue("freshness.thresholdInHours", "configmap center/validator (config.yaml)"))
+		Expect(loaded.Origins).To(HaveKeyWithValue("exempt.labelValue", "default"))
+
+		var printed bytes.Buffer
+		Expect(loaded.Print(&printed)).To(Succeed())
+		Expect(printed.String()).To(ContainSubstring("abort.configMapName: \"override\" # file /override.yaml\n"))
+		Expect(printed.String()).To(ContainSubstring("notifications.webhooks: <redacted> # secret center/validator (webhooks)\n"))
+		Expect(printed.String()).NotTo(ContainSubstring("token"))
+	})
+
+	It("environment variables of Services are ignored in strict mode", func() {
+		for name, value := range map[string]string{
+			"TEST_SERVICE_HOST":               "10.0.0.1",
+			"TEST_SERVICE_PORT":               "8080",
+			"TEST_SERVICE_PORT_HTTP":          "8080",
+			"TEST_PORT":                       "tcp://10.0.0.1:8080",
+			"TEST_PORT_8080_TCP":              "tcp://10.0.0.1:8080",
+			"TEST_PORT_8080_TCP_ADDR":         "10.0.0.1",
+			"TEST_FRESHNESS_THRESHOLDINHOURS": "48",
+		} {
+			os.Setenv(name, value)
+			defer os.Unsetenv(name)
+		}
+
+		loaded, err := ConfigLoader{EnvPrefix: "TEST_", Strict: true}.Load(ctx, appFs, nil)
+		Expect(err).To(Succeed())
+		Expect(loaded.Warnings).To(BeEmpty())
+		Expect(loaded.Config.Freshness.ThresholdInHours).To(Equal(int32(48)))
+		Expect(DefaultConfigLoader().EnvPrefix).To(Equal("KRV_CONFIG_"))
+	})
+
+	It("invalid layered configuration", func() {
+		os.Setenv("TEST_FRESHNESS_THRESHOLDINHOURS", "soon")
+		defer os.Unsetenv("TEST_FRESHNESS_THRESHOLDINHOURS")
+		loader := ConfigLoader{Files: []string{"/missing.yaml"}, EnvPrefix: "TEST_", Values: []string{"abort.configMapNme=typo"}}
+
+		loaded, err := loader.Load(ctx, appFs, nil)
+		Expect(err).To(Succeed())
+		Expect(loaded.Warnings).To(ConsistOf(
+			"couldn't find configuration file /missing.yaml",
+			ContainSubstring("environment variable TEST_FRESHNESS_THRESHOLDINHOURS: cannot unmarshal !!str `soon`"),
+			"flag --set abort.configMapNme: unknown key abort.configMapNme",
+		))
+		loaded.Config.Values = nil
+		Expect(loaded.Config).To(Equal(common.NewConfig()))
+
+		loader.Strict = true
+		_, err = loader.Load(ctx, appFs, nil)
+		Expect(err).To(MatchError(ContainSubstring("TEST_FRESHNESS_THRESHOLDINHOURS")))
+		Expect(err).To(MatchError(ContainSubstring("/missing.yaml")))
+
+		validation, err := NewValidation(ctx)
+		Expect(err).To(Succeed())
+		_, err = validation.LoadConfig(loader)
+		Expect(err).To(HaveOccurred())
+		Expect(validation.GetConfig().Freshness).To(Equal(common.NewConfig().Freshness))
 	})
 })
+
+type indexAwareValidator struct {
+	index *common.ResourceIndex
+}
+
+func (v *indexAwareValidator) SetResourceIndex(index *common.ResourceIndex) {
+	v.index = index
+}
+
+func (v *indexAwareValidator) Validate(resources []unstructured.Unstructured) ([]common.Violation, error) {
+	return nil, nil
+}
+
+func (v *indexAwareValidator) GetName() string {
+	return "index-aware"
+}
+
+/*
+perResourceValidator reports a violation for each resource of the kind
+*/
+type perResourceValidator struct {
+	kind string
+}
+
+func (v *perResourceValidator) Validate(resources []unstructured.Unstructured) ([]common.Violation, error) {
+	var violations []common.Violation
+	for _, resource := range resources {
+		if resource.GetKind() == v.kind {
+			violations = append(violations, common.NewViolation(resource, "violation", common.SeverityHigh.Level(), v.GetName()).WithRuleID("per-resource"))
+		}
+	}
+	return violations, nil
+}
+
+func (v *perResourceValidator) GetName() string {
+	return "per-resource"
+}
+
+type configAwareValidator struct {
+	config common.Config
+}
+
+func (v *configAwareValidator) SetConfig(config common.Config) {
+	v.config = config
+}
+
+func (v *configAwareValidator) Validate(resources []unstructured.Unstructured) ([]common.Violation, error) {
+	return nil, nil
+}
+
+func (v *configAwareValidator) GetName() string {
+	return "config-aware"
+}
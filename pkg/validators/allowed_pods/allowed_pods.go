@@ -4,29 +4,51 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 	"github.com/go-logr/logr"
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
 	allowlistFile = "allowlist.yaml"
 	ValidatorName = "built-in:allowed-pods"
+
+	ruleNotInAllowlist   = "not-in-allowlist"
+	ruleStaleEntry       = "stale-allowlist-entry"
+	ruleInvalidConfigMap = "invalid-allowlist-configmap" // see common.ListDocument.NewViolation()
+	documentationURL     = common.DocumentationURL + "#allowed-pods"
 )
 
+/*
+AllowlistItem allows a resource, and its dependents (e.g. the Pods of an allowed Deployment).
+Name and Namespace are matched exactly, by a glob (e.g. "backup-*"), or by a regular expression enclosed in slashes (e.g. "/^tenant-[0-9]+$/").
+*/
 type AllowlistItem struct {
 	Name      string `yaml:"name"`
-	Namespace string `yaml:"namespace"`
+	Namespace string `yaml:"namespace,omitempty"`
 	Kind      string `yaml:"kind"`
+	Group     string `yaml:"group,omitempty"`    // API group, e.g. "apps", or "core"; matches all groups if empty
+	Selector  string `yaml:"selector,omitempty"` // label selector (kubectl syntax), e.g. "app in (agent, proxy)"; optional
+	/*
+		Constraints restrict what the allowed resource and its dependents may run; optional.
+		If several entries allow a pod, it's allowed if it satisfies the constraints of any of them.
+	*/
+	Constraints *AllowlistConstraints `yaml:"constraints,omitempty"`
 }
 
 func NewAllowedPodsValidator(ctx context.Context, configDir string) (common.Validator, error) {
 	response := AllowedPodsValidator{
 		configDir:   configDir,
 		allowedPods: []unstructured.Unstructured{},
+		config:      common.NewConfig(),
 		ctx:         ctx,
 	}
 
@@ -44,6 +66,14 @@ type AllowedPodsValidator struct {
 	configDir   string
 	appFs       afero.Fs
 	allowedPods []unstructured.Unstructured
+	index       *common.ResourceIndex
+	scopedOut   []unstructured.Unstructured      // of the next run, see SetScopedOut()
+	allowlist   atomic.Pointer[[]allowlistEntry] // nil until allowlist.yaml is read by a run (or by Reload())
+	watched     atomic.Bool                      // reloaded by a watcher, rather than read by each run
+	reportStale bool                             // see common.AllowedPodsConfig
+	configMaps  common.ListConfigMapsConfig      // see common.AllowedPodsConfig
+	clientSet   kubernetes.Interface             // reads the ConfigMaps
+	config      common.Config                    // pods with its exempt label are skipped; see SetConfig()
 	ctx         context.Context
 	logger      logr.Logger
 }
@@ -52,91 +82,321 @@ func (v *AllowedPodsValidator) GetName() string {
 	return ValidatorName
 }
 
+/*
+SetResourceIndex sets the index of the resources passed to the next call of Validate(), which clears it.
+If it isn't set, an index is built by Validate().
+*/
+func (v *AllowedPodsValidator) SetResourceIndex(index *common.ResourceIndex) {
+	v.index = index
+}
+
+/*
+SetScopedOut sets the resources that were scoped out of the next call of Validate(), which clears them.
+Stale entries of these resources aren't reported, since they still exist (e.g. while their workload is rolling out).
+*/
+func (v *AllowedPodsValidator) SetScopedOut(resources []unstructured.Unstructured) {
+	v.scopedOut = resources
+}
+
+/*
+SetConfig applies the allowedPods section of the configuration
+*/
+func (v *AllowedPodsValidator) SetConfig(config common.Config) {
+	v.config = config
+	v.reportStale = config.AllowedPods.ReportStaleEntries
+	v.configMaps = config.AllowedPods.ConfigMaps
+}
+
+/*
+SetClientSet sets the client, which the allowlist ConfigMaps are read with
+*/
+func (v *AllowedPodsValidator) SetClientSet(clientSet kubernetes.Interface) {
+	v.clientSet = clientSet
+}
+
+/*
+WatchedFiles returns the paths of allowlist.yaml and of the files in allowlist.d/
+*/
+func (v *AllowedPodsValidator) WatchedFiles() []string {
+	paths, err := common.ListFiles(v.appFs, v.configDir, allowlistFile)
+	if err != nil || !slices.Contains(paths, filepath.Join(v.configDir, allowlistFile)) {
+		paths = append([]string{filepath.Join(v.configDir, allowlistFile)}, paths...)
+	}
+	return paths
+}
+
+/*
+SetWatched sets whether a watcher reloads allowlist.yaml and the files in allowlist.d/ when they change (see reload.NewWatcher).
+Otherwise, each run reads them.
+*/
+func (v *AllowedPodsValidator) SetWatched(watched bool) {
+	v.watched.Store(watched)
+}
+
+/*
+Reload reads allowlist.yaml and the files in allowlist.d/, and replaces the allowlist of subsequent runs if they're valid.
+The allowlist ConfigMaps are read by each run instead.
+*/
+func (v *AllowedPodsValidator) Reload() error {
+	allowlist, err := v.readAllowlist(v.configDir)
+	if err != nil {
+		return err
+	}
+
+	v.allowlist.Store(&allowlist)
+	return nil
+}
+
 func (v *AllowedPodsValidator) Validate(resources []unstructured.Unstructured) ([]common.Violation, error) {
 	pods := common.GetPods(resources)
-	rawAllowlist, err := v.readAllowlist(v.configDir)
+	if !v.watched.Load() || v.allowlist.Load() == nil {
+		if err := v.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	allowlist := *v.allowlist.Load()
+	configMapEntries, violations, err := v.readConfigMaps()
 	if err != nil {
 		return nil, err
 	}
+	allowlist = append(slices.Clip(allowlist), configMapEntries...)
+	// the index only belongs to the resources of this call
+	index := v.index
+	v.index = nil
+	scopedOut := v.scopedOut
+	v.scopedOut = nil
+	if index == nil {
+		index = common.NewResourceIndex(resources)
+	}
 
-	allowlist := allowListToUnstructured(rawAllowlist)
-
-	var violations []common.Violation
+	v.allowedPods = []unstructured.Unstructured{} // of this call
+	reportedWorkloads := map[common.ResourceKey]bool{} // whose replicas exceed the maximum, which is reported once rather than by each pod
+	used := map[allowlistEntryKey]bool{}               // the entries that matched a pod, or one of its owners
 	for _, pod := range pods {
 		namespace, name := pod.GetNamespace(), pod.GetName()
-		if common.IsExempt(pod) {
+		if v.config.IsExempt(pod) {
 			v.logger.V(2).Info(fmt.Sprintf("is exempt: %s/%s", namespace, name))
 			continue
 		}
 
-		if isInAllowlist(resources, allowlist, pod) {
-			v.logger.V(2).Info(fmt.Sprintf("found in allowlist: %s/%s", namespace, name))
-			v.allowedPods = append(v.allowedPods, pod)
+		if matches := allowlistMatches(index, allowlist, &pod); len(matches) > 0 {
+			for _, match := range matches {
+				used[match.entry.key()] = true
+			}
+			constraintViolations, err := checkConstraints(&pod, matches)
+			if err != nil {
+				return nil, err
+			}
+			if len(constraintViolations) == 0 {
+				v.logger.V(2).Info(fmt.Sprintf("found in allowlist: %s/%s", namespace, name))
+				v.allowedPods = append(v.allowedPods, pod)
+				continue
+			}
+
+			v.logger.V(2).Info(fmt.Sprintf("found in allowlist, but violates its constraints: %s/%s", namespace, name))
+			for _, violation := range constraintViolations {
+				if violation.RuleID == ruleReplicasExceeded {
+					workload := common.NewResourceKey(violation.Resource)
+					if reportedWorkloads[workload] {
+						continue
+					}
+					reportedWorkloads[workload] = true
+				}
+				violations = append(violations, violation)
+			}
 			continue
 		}
-		violation := common.NewViolation(pod, "NOT found in allowlist", 1, ValidatorName)
+		violation := common.NewViolation(pod, "NOT found in allowlist", common.SeverityHigh.Level(), ValidatorName).
+			WithRuleID(ruleNotInAllowlist).
+			WithRemediation(fmt.Sprintf("add the pod, or one of its owners, to %s", allowlistFile)).
+			WithDocumentationURL(documentationURL)
+		if owners := describeOwners(pod); owners != "" {
+			violation = violation.WithDetail("owners", owners)
+		}
 		violations = append(violations, violation)
 	}
+
+	if v.reportStale {
+		violations = append(violations, staleEntries(allowlist, append(slices.Clip(resources), scopedOut...), used)...)
+	}
 	return violations, nil
 }
 
-func (v *AllowedPodsValidator) readAllowlist(dir string) ([]AllowlistItem, error) {
-	var allowlistFileFullPath = filepath.Join(dir, allowlistFile)
-	var allowlist []AllowlistItem
-
-	content, err := afero.ReadFile(v.appFs, allowlistFileFullPath)
+func (v *AllowedPodsValidator) readAllowlist(dir string) ([]allowlistEntry, error) {
+	documents, err := common.ReadListFiles(v.appFs, dir, allowlistFile)
 	if err != nil {
-		v.logger.Error(err, "couldn't read allowlist file", allowlistFileFullPath)
+		v.logger.Error(err, "couldn't read allowlist file")
 		return nil, err
-	} else {
-		err := yaml.Unmarshal(content, &allowlist)
+	}
+
+	var response []allowlistEntry
+	for _, document := range documents {
+		entries, err := parseAllowlist(document, v.logger)
 		if err != nil {
-			v.logger.Error(err, "couldn't parse allowlist file")
+			v.logger.Error(err, "invalid allowlist file")
 			return nil, err
 		}
+		response = append(response, entries...)
+	}
+	return response, nil
+}
+
+/*
+readConfigMaps reads the allowlist entries of the ConfigMaps selected by the configuration.
+Problems of a ConfigMap (e.g. entries of namespaces that it doesn't own) are reported as violations of the ConfigMap,
+rather than failing the run, and its offending entries are ignored.
+*/
+func (v *AllowedPodsValidator) readConfigMaps() ([]allowlistEntry, []common.Violation, error) {
+	documents, err := common.ReadListConfigMaps(v.ctx, v.clientSet, v.configMaps, allowlistFile)
+	if err != nil {
+		v.logger.Error(err, "couldn't read allowlist ConfigMaps")
+		return nil, nil, err
 	}
 
-	return allowlist, nil
+	var entries []allowlistEntry
+	var violations []common.Violation
+	for _, document := range documents {
+		parsed, err := parseAllowlist(document, v.logger)
+		if err != nil {
+			v.logger.Error(err, "invalid allowlist ConfigMap")
+			violations = append(violations, document.NewViolation(ValidatorName, ruleInvalidConfigMap, err.Error(),
+				"fix the allowlist in the ConfigMap; none of its entries apply until then").
+				WithDocumentationURL(documentationURL))
+			continue
+		}
+
+		for _, entry := range parsed {
+			if !document.Owns(entry.item.Namespace) {
+				violations = append(violations, document.NewViolation(ValidatorName, common.RuleNamespaceNotOwned,
+					fmt.Sprintf("the allowlist entry %s is ignored, since the ConfigMap may only list resources of namespace %s", entry, document.Owner),
+					"move the entry to a ConfigMap in the entry's namespace").
+					WithDocumentationURL(documentationURL).
+					WithDetail("entry", strconv.Itoa(entry.position)))
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, violations, nil
 }
 
-func isInAllowlist(allResources []unstructured.Unstructured, allowlist []unstructured.Unstructured, item unstructured.Unstructured) bool {
-	idx := common.IndexFunc(allowlist, func(itemIter unstructured.Unstructured) bool {
-		return item.GetKind() == itemIter.GetKind() &&
-			item.GetName() == itemIter.GetName() &&
-			item.GetNamespace() == itemIter.GetNamespace()
-	})
+/*
+parseAllowlist parses a document of the allowlist, e.g. allowlist.yaml.
+Items without a name or kind can't match any resource; they are logged and ignored, as they used to be.
+*/
+func parseAllowlist(document common.ListDocument, logger logr.Logger) ([]allowlistEntry, error) {
+	var items []AllowlistItem
+	if err := yaml.Unmarshal(document.Content, &items); err != nil {
+		return nil, fmt.Errorf("%s: %w", document.Origin, err)
+	}
 
-	if idx > -1 {
-		return true
+	response := make([]allowlistEntry, 0, len(items))
+	for i, item := range items {
+		if item.Name == "" || item.Kind == "" {
+			logger.V(1).Info(fmt.Sprintf("ignoring item %d of %s, since it has no name or kind", i+1, document.Origin))
+			continue
+		}
+
+		entry, err := newAllowlistEntry(item, document.Origin, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: item %d: %w", document.Origin, i+1, err)
+		}
+		entry.trusted = document.Owner == ""
+		response = append(response, entry)
 	}
+	return response, nil
+}
+
+/*
+allowlistMatches returns the allowlist entries that match the item, or any of its owners (recursively), the item's first.
+Owners are matched by their owner references (including the API group of their apiVersion), so an owner that doesn't exist
+(e.g. since its kind wasn't fetched) may match as well, unless the matching allowlist entries have label selectors.
+*/
+func allowlistMatches(index *common.ResourceIndex, allowlist []allowlistEntry, item *unstructured.Unstructured) []allowlistMatch {
+	var response []allowlistMatch
+	visited := map[*unstructured.Unstructured]bool{}
+	var collect func(item *unstructured.Unstructured)
+	collect = func(item *unstructured.Unstructured) {
+		for _, entry := range allowlist {
+			if entry.matchesResource(item) {
+				response = append(response, allowlistMatch{entry: entry, resource: item})
+			}
+		}
 
-	ownerReferences, err := common.GetOwnerReferences(allResources, item)
-	if err == nil {
-		for _, s := range ownerReferences {
-			owner := unstructured.Unstructured{}
-			owner.SetName(s.Name)
-			owner.SetNamespace(item.GetNamespace())
-			owner.SetKind(s.Kind)
-			found := isInAllowlist(allResources, allowlist, owner)
+		for _, reference := range item.GetOwnerReferences() {
+			owner, found := index.Owner(item, reference)
 			if found {
-				return true
+				if !visited[owner] {
+					visited[owner] = true
+					collect(owner)
+				}
+				continue
+			}
+
+			for _, entry := range allowlist {
+				if entry.matches(reference.Kind, groupOf(reference.APIVersion), item.GetNamespace(), reference.Name, nil, false) {
+					response = append(response, allowlistMatch{entry: entry})
+				}
 			}
 		}
 	}
 
-	return false
+	collect(item)
+	return response
 }
 
-func allowListToUnstructured(allowList []AllowlistItem) []unstructured.Unstructured {
-	response := make([]unstructured.Unstructured, len(allowList))
+/*
+checkConstraints returns no violations if the pod satisfies the constraints of any of the matches, and the violations of the first match otherwise.
+If any of the matches is trusted, only trusted matches are considered, so that the entries of teams' ConfigMaps can't lift the constraints of trusted entries.
+*/
+func checkConstraints(pod *unstructured.Unstructured, matches []allowlistMatch) ([]common.Violation, error) {
+	if slices.ContainsFunc(matches, func(match allowlistMatch) bool { return match.entry.trusted }) {
+		matches = slices.DeleteFunc(slices.Clone(matches), func(match allowlistMatch) bool { return !match.entry.trusted })
+	}
 
-	for i, e := range allowList {
-		obj := unstructured.Unstructured{}
-		obj.SetName(e.Name)
-		obj.SetNamespace(e.Namespace)
-		obj.SetKind(e.Kind)
-		response[i] = obj
+	var response []common.Violation
+	for i, match := range matches {
+		violations, err := match.check(pod)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) == 0 {
+			return nil, nil
+		}
+		if i == 0 {
+			response = violations
+		}
 	}
+	return response, nil
+}
 
-	return response
+/*
+staleEntries reports the allowlist entries that matched no resource, neither a pod (or one of its owners), nor any other fetched resource
+(e.g. a Deployment that is scaled to zero), including those that were scoped out of the run
+*/
+func staleEntries(allowlist []allowlistEntry, resources []unstructured.Unstructured, used map[allowlistEntryKey]bool) []common.Violation {
+	var violations []common.Violation
+	for _, entry := range allowlist {
+		if used[entry.key()] || slices.ContainsFunc(resources, func(resource unstructured.Unstructured) bool { return entry.matchesResource(&resource) }) {
+			continue
+		}
+
+		violations = append(violations, common.NewViolation(entry.toUnstructured(), "allowlist entry matched no resource", common.SeverityLow.Level(), ValidatorName).
+			WithRuleID(ruleStaleEntry).
+			WithRemediation(fmt.Sprintf("remove the entry from %s, unless the resource is expected to return", entry.origin)).
+			WithDocumentationURL(documentationURL).
+			WithDetail("entry", strconv.Itoa(entry.position)).
+			WithDetail("origin", entry.origin))
+	}
+	return violations
+}
+
+// describeOwners lists the pod's direct owners, e.g. "ReplicaSet/name"
+func describeOwners(pod unstructured.Unstructured) string {
+	var owners []string
+	for _, owner := range pod.GetOwnerReferences() {
+		owners = append(owners, fmt.Sprintf("%s/%s", owner.Kind, owner.Name))
+	}
+	return strings.Join(owners, ", ")
 }
@@ -11,8 +11,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/afero"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 	"github.com/SAP/k8s-resource-validator/pkg/test_utils"
@@ -100,19 +105,20 @@ var _ = Describe("Allowed Pods", func() {
 			Expect(err).To(Succeed())
 
 			allowedPodUnstructuredResource.SetName("not-allowed")
+			allowedPodUnstructuredResource.SetOwnerReferences([]metav1.OwnerReference{{Kind: common.KIND_REPLICA_SET, Name: "not-allowed-rs"}})
 			violationsArray, err := allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleNotInAllowlist))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("owners", "ReplicaSet/not-allowed-rs"))
 
 			allowedPods := allowedPodsValidator.(*AllowedPodsValidator).allowedPods
 			Expect(allowedPods).To(HaveLen(0))
 		})
 
 		It("pod is NOT in allowlist, but is exempt", func() {
-			common.ExemptPodLabelName = "label1"
-			common.ExemptPodLabelValue = "exempt"
 			labels := make(map[string]string)
-			labels["label1"] = "exempt"
+			labels[common.NewConfig().Exempt.LabelName] = common.NewConfig().Exempt.LabelValue
 
 			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
 			Expect(err).To(Succeed())
@@ -128,6 +134,371 @@ var _ = Describe("Allowed Pods", func() {
 			Expect(allowedPods).To(HaveLen(0))
 		})
 
+		DescribeTable("allowlist patterns, groups and selectors",
+			func(allowlist string, ownerAPIVersion string, expectedViolations int) {
+				Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte(allowlist), 0644)).To(Succeed())
+				allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+				Expect(err).To(Succeed())
+
+				allowedPodUnstructuredResource.SetName("backup-28500")
+				allowedPodUnstructuredResource.SetNamespace("tenant-42")
+				allowedPodUnstructuredResource.SetLabels(map[string]string{"app": "backup"})
+				allowedPodUnstructuredResource.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: ownerAPIVersion, Kind: "Job", Name: "backup-28500"}})
+				violationsArray, err := allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})
+				Expect(err).To(Succeed())
+				Expect(violationsArray).To(HaveLen(expectedViolations))
+			},
+			Entry("glob name", "- {name: backup-*, namespace: tenant-42, kind: Pod}", "batch/v1", 0),
+			Entry("glob name mismatch", "- {name: restore-*, namespace: tenant-42, kind: Pod}", "batch/v1", 1),
+			Entry("regular expression namespace", "- {name: backup-28500, namespace: '/^tenant-[0-9]+$/', kind: Pod}", "batch/v1", 0),
+			Entry("regular expression is anchored by the expression only", "- {name: backup-28500, namespace: '/^tenant-[a-z]+$/', kind: Pod}", "batch/v1", 1),
+			Entry("label selector", "- {name: '*', namespace: '*', kind: Pod, selector: 'app in (backup, restore)'}", "batch/v1", 0),
+			Entry("label selector mismatch", "- {name: '*', namespace: '*', kind: Pod, selector: app=restore}", "batch/v1", 1),
+			Entry("core group", "- {name: '*', namespace: '*', kind: Pod, group: core}", "batch/v1", 0),
+			Entry("owner of group", "- {name: backup-*, namespace: '*', kind: Job, group: batch}", "batch/v1", 0),
+			Entry("owner of another group", "- {name: backup-*, namespace: '*', kind: Job, group: batch}", "example.com/v1", 1),
+			Entry("owner without labels doesn't match selectors", "- {name: backup-*, namespace: '*', kind: Job, selector: app=backup}", "batch/v1", 1),
+		)
+
+		It("items without a name or kind are ignored", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {namespace: namespace}\n- {name: "+podName+", namespace: namespace, kind: Pod}"), 0644)).To(Succeed())
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(BeEmpty())
+		})
+
+		It("invalid allowlist patterns", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: '/[/', namespace: default, kind: Pod}"), 0644)).To(Succeed())
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			_, err = allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})
+			Expect(err).To(MatchError(ContainSubstring("item 1: name: invalid regular expression /[/")))
+
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: app, namespace: default, kind: Pod, selector: 'app in'}"), 0644)).To(Succeed())
+			_, err = allowedPodsValidator.(*AllowedPodsValidator).readAllowlist(configDirectory)
+			Expect(err).To(MatchError(ContainSubstring("invalid selector")))
+		})
+
+		DescribeTable("allowlist constraints",
+			func(constraints string, expectedRules []string) {
+				allowlist := "- {name: app, namespace: namespace, kind: Deployment, group: apps, constraints: " + constraints + "}\n" +
+					"- {name: app, namespace: namespace, kind: Deployment, constraints: {serviceAccount: other}}\n"
+				Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte(allowlist), 0644)).To(Succeed())
+				allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+				Expect(err).To(Succeed())
+
+				privileged := true
+				pods := make([]unstructured.Unstructured, 2)
+				for i := range pods {
+					pods[i] = toUnstructured(&corev1.Pod{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+						ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: fmt.Sprintf("app-%d", i),
+							OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT, Name: "app", UID: "app"}}},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: "app",
+							Containers: []corev1.Container{
+								{Name: "app", Image: "registry.example.com/team/app:1.0"},
+								{Name: "sidecar", Image: "registry.example.com/proxy@sha256:abc", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}},
+							},
+						},
+					})
+				}
+				replicas := int32(2)
+				deployment := toUnstructured(&appsv1.Deployment{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT},
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app", UID: "app"},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				})
+
+				violationsArray, err := allowedPodsValidator.Validate(append(pods, deployment))
+				Expect(err).To(Succeed())
+				var rules []string
+				for _, violation := range violationsArray {
+					rules = append(rules, violation.RuleID)
+				}
+				Expect(rules).To(Equal(expectedRules))
+			},
+			Entry("satisfied", "{images: [registry.example.com/team/*, 'registry.example.com/proxy@sha256:abc'], serviceAccount: app, maxReplicas: 2, privileged: true}", nil),
+			Entry("image of another repository", "{images: [registry.example.com/team/*]}",
+				[]string{ruleImageNotPermitted, ruleImageNotPermitted}),
+			Entry("image of another tag", "{images: ['registry.example.com/team/app:2.0', registry.example.com/proxy]}",
+				[]string{ruleImageNotPermitted, ruleImageNotPermitted}),
+			Entry("service account", "{serviceAccount: default}",
+				[]string{ruleServiceAccountNotPermitted, ruleServiceAccountNotPermitted}),
+			Entry("privileged", "{privileged: false}",
+				[]string{rulePrivilegedNotPermitted, rulePrivilegedNotPermitted}),
+			Entry("replicas are reported once", "{maxReplicas: 1}",
+				[]string{ruleReplicasExceeded}),
+		)
+
+		DescribeTable("stale entries refer to a valid apiVersion",
+			func(group string, expectedAPIVersion string, expectedGroup string) {
+				entry, err := newAllowlistEntry(AllowlistItem{Name: "app", Namespace: namespace, Kind: "Thing", Group: group}, allowlistFile, 1)
+				Expect(err).To(Succeed())
+				resource := entry.toUnstructured()
+				Expect(resource.GetAPIVersion()).To(Equal(expectedAPIVersion))
+				Expect(common.NewViolationTarget(&resource).Group).To(Equal(expectedGroup))
+			},
+			Entry("all groups", "", "", ""),
+			Entry("core group", coreGroup, "v1", ""),
+			Entry("named group", "apps", "apps/v1", "apps"),
+		)
+
+		It("reports stale allowlist entries if configured", func() {
+			allowlist := "- {name: name, namespace: namespace, kind: Pod}\n" + // the pod
+				"- {name: rs1, namespace: namespace, kind: ReplicaSet}\n" + // the pod's owner, by reference
+				"- {name: idle, namespace: namespace, kind: Deployment, group: apps}\n" + // scaled to zero
+				"- {name: gone, namespace: namespace, kind: Deployment, group: apps}\n"
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte(allowlist), 0644)).To(Succeed())
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+
+			allowedPodUnstructuredResource.SetOwnerReferences([]metav1.OwnerReference{{Kind: common.KIND_REPLICA_SET, Name: replicaSetName}})
+			idle := toUnstructured(&appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT},
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "idle"},
+			})
+			resources := []unstructured.Unstructured{allowedPodUnstructuredResource, idle}
+
+			violationsArray, err := allowedPodsValidator.Validate(resources)
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(BeEmpty())
+
+			config := common.NewConfig()
+			config.AllowedPods.ReportStaleEntries = true
+			allowedPodsValidator.(common.ConfigAware).SetConfig(config)
+			violationsArray, err = allowedPodsValidator.Validate(resources)
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleStaleEntry))
+			Expect(violationsArray[0].Severity()).To(Equal(common.SeverityLow))
+			Expect(common.NewResourceKey(violationsArray[0].Resource).String()).To(Equal("Deployment.apps/namespace/gone"))
+			Expect(violationsArray[0].Resource.GetAPIVersion()).To(Equal("apps/v1"))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("entry", "4"))
+			Expect(violationsArray[0].Remediation).To(ContainSubstring(filepath.Join(configDirectory, allowlistFile)))
+
+			// the resources of workloads that are rolling out are scoped out of the run, but they still exist
+			gone := toUnstructured(&appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT},
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "gone"},
+			})
+			allowedPodsValidator.(common.ScopeAware).SetScopedOut([]unstructured.Unstructured{gone})
+			Expect(allowedPodsValidator.Validate(resources)).To(BeEmpty())
+			Expect(allowedPodsValidator.Validate(resources)).To(HaveLen(1)) // only the next run is scoped
+		})
+
+		It("allowlist files and ConfigMaps", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, "allowlist.d", "team-c.yaml"), []byte("- {name: c, namespace: team-c, kind: Pod}"), 0644)).To(Succeed())
+			allowlistConfigMap := func(namespace string, allowlist string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "allowlist", Labels: map[string]string{"allowlist": "true"}},
+					Data:       map[string]string{allowlistFile: allowlist},
+				}
+			}
+			clientSet := k8sfake.NewSimpleClientset(
+				allowlistConfigMap("team-a", "- {name: a, namespace: team-a, kind: Pod}\n- {name: b, namespace: team-b, kind: Pod}"),
+				allowlistConfigMap("team-d", "- {name: '/[/', namespace: team-d, kind: Pod}"),
+			)
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			Expect(allowedPodsValidator.(common.Reloadable).WatchedFiles()).To(Equal([]string{
+				filepath.Join(configDirectory, allowlistFile), filepath.Join(configDirectory, "allowlist.d", "team-c.yaml")}))
+			config := common.NewConfig()
+			config.AllowedPods.ConfigMaps = common.ListConfigMapsConfig{Selector: "allowlist=true"}
+			allowedPodsValidator.(common.ConfigAware).SetConfig(config)
+			allowedPodsValidator.(common.ClientAware).SetClientSet(clientSet)
+
+			var pods []unstructured.Unstructured
+			for _, name := range []string{"a", "b", "c"} {
+				pod := test_utils.CreateUnstructuredPodResource(false, name, "team-"+name, containerName)
+				pods = append(pods, pod)
+			}
+			violationsArray, err := allowedPodsValidator.Validate(pods)
+			Expect(err).To(Succeed())
+
+			var rules []string
+			for _, violation := range violationsArray {
+				rules = append(rules, violation.RuleID+" "+common.NewResourceKey(violation.Resource).String())
+			}
+			Expect(rules).To(ConsistOf(
+				common.RuleNamespaceNotOwned+" ConfigMap/team-a/allowlist", // team-a can't allowlist pods of team-b
+				ruleInvalidConfigMap+" ConfigMap/team-d/allowlist",
+				ruleNotInAllowlist+" Pod/team-b/b",
+			))
+		})
+
+		It("ConfigMaps can't lift the constraints of trusted entries", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: agent, namespace: team-a, kind: Pod, constraints: {privileged: false}}"), 0644)).To(Succeed())
+			clientSet := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "allowlist", Labels: map[string]string{"allowlist": "true"}},
+				Data:       map[string]string{allowlistFile: "- {name: agent, namespace: team-a, kind: Pod}"},
+			})
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			config := common.NewConfig()
+			config.AllowedPods.ConfigMaps = common.ListConfigMapsConfig{Selector: "allowlist=true"}
+			allowedPodsValidator.(common.ConfigAware).SetConfig(config)
+			allowedPodsValidator.(common.ClientAware).SetClientSet(clientSet)
+
+			pod := test_utils.CreateUnstructuredPodResource(true, "agent", "team-a", containerName)
+			violationsArray, err := allowedPodsValidator.Validate([]unstructured.Unstructured{pod})
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(rulePrivilegedNotPermitted))
+		})
+
+		It("replicas are reported once per workload", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: '*', namespace: team-a, kind: Deployment, constraints: {maxReplicas: 1}}"), 0644)).To(Succeed())
+			// matches the pods' other owners, which weren't fetched, before the trusted entry matches their Deployments
+			clientSet := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "allowlist", Labels: map[string]string{"allowlist": "true"}},
+				Data:       map[string]string{allowlistFile: "- {name: '*', namespace: team-a, kind: Group}"},
+			})
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			config := common.NewConfig()
+			config.AllowedPods.ConfigMaps = common.ListConfigMapsConfig{Selector: "allowlist=true"}
+			allowedPodsValidator.(common.ConfigAware).SetConfig(config)
+			allowedPodsValidator.(common.ClientAware).SetClientSet(clientSet)
+
+			replicas := int32(3)
+			var resources []unstructured.Unstructured
+			for _, name := range []string{"a", "b"} {
+				resources = append(resources, toUnstructured(&appsv1.Deployment{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT},
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID(name)},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				}))
+				for i := range 2 {
+					resources = append(resources, toUnstructured(&corev1.Pod{
+						TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD},
+						ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: fmt.Sprintf("%s-%d", name, i), OwnerReferences: []metav1.OwnerReference{
+							{APIVersion: "example.com/v1", Kind: "Group", Name: "group", UID: "group"},
+							{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT, Name: name, UID: types.UID(name)},
+						}},
+					}))
+				}
+			}
+
+			violationsArray, err := allowedPodsValidator.Validate(resources)
+			Expect(err).To(Succeed())
+			var rules []string
+			for _, violation := range violationsArray {
+				rules = append(rules, violation.RuleID+" "+common.NewResourceKey(violation.Resource).String())
+			}
+			Expect(rules).To(Equal([]string{
+				ruleReplicasExceeded + " Deployment.apps/team-a/a",
+				ruleReplicasExceeded + " Deployment.apps/team-a/b",
+			}))
+		})
+
+		It("allowed pods are those of the last run", func() {
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			for range 2 {
+				Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(BeEmpty())
+				Expect(allowedPodsValidator.(*AllowedPodsValidator).allowedPods).To(HaveLen(1))
+			}
+		})
+
+		It("generates an allowlist that allows the current pods", func() {
+			controller := true
+			newResource := func(object runtime.Object, namespace string, name string, owners ...metav1.OwnerReference) unstructured.Unstructured {
+				resource := toUnstructured(object)
+				resource.SetNamespace(namespace)
+				resource.SetName(name)
+				resource.SetUID(types.UID(namespace + "/" + name))
+				resource.SetOwnerReferences(owners)
+				return resource
+			}
+			ownedBy := func(apiVersion string, kind string, namespace string, name string, isController bool) metav1.OwnerReference {
+				return metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(namespace + "/" + name), Controller: &isController}
+			}
+			pod := &corev1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: common.KIND_POD}}
+			resources := []unstructured.Unstructured{
+				newResource(&appsv1.Deployment{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT}}, "team-a", "app"),
+				newResource(&appsv1.ReplicaSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: common.KIND_REPLICA_SET}}, "team-a", "app-1",
+					ownedBy("apps/v1", common.KIND_DEPLOYMENT, "team-a", "app", controller)),
+				newResource(pod, "team-a", "app-1-a", ownedBy("apps/v1", common.KIND_REPLICA_SET, "team-a", "app-1", controller)),
+				newResource(pod, "team-a", "app-1-b", ownedBy("apps/v1", common.KIND_REPLICA_SET, "team-a", "app-1", controller)),
+				// the Job's CronJob wasn't fetched
+				newResource(pod, "team-b", "backup-1-a", ownedBy("batch/v1", common.KIND_JOB, "team-b", "backup-1", controller)),
+				newResource(pod, "team-b", "debug"),
+				// the controller is preferred to other owners
+				newResource(pod, "team-b", "agent-a",
+					ownedBy("v1", common.KIND_CONFIG_MAP, "team-b", "agent", !controller), ownedBy("apps/v1", common.KIND_DAEMON_SET, "team-b", "agent", controller)),
+			}
+
+			items := GenerateAllowlist(common.NewResourceIndex(resources))
+			Expect(items).To(Equal([]AllowlistItem{
+				{Name: "app", Namespace: "team-a", Kind: common.KIND_DEPLOYMENT, Group: "apps"},
+				{Name: "agent", Namespace: "team-b", Kind: common.KIND_DAEMON_SET, Group: "apps"},
+				{Name: "backup-1", Namespace: "team-b", Kind: common.KIND_JOB, Group: "batch"},
+				{Name: "debug", Namespace: "team-b", Kind: common.KIND_POD},
+			}))
+
+			Expect(WriteAllowlistByNamespace(appFs, filepath.Join(configDirectory, "allowlist.d"), items)).To(Succeed())
+			Expect(WriteAllowlist(appFs, filepath.Join(configDirectory, allowlistFile), nil)).To(Succeed())
+			content, err := afero.ReadFile(appFs, filepath.Join(configDirectory, "allowlist.d", "team-b.yaml"))
+			Expect(err).To(Succeed())
+			Expect(string(content)).To(HavePrefix(generatedHeader + "- name: agent\n  namespace: team-b\n  kind: DaemonSet\n  group: apps\n"))
+
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			violationsArray, err := allowedPodsValidator.Validate(resources)
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(BeEmpty())
+		})
+
+		It("removes the generated files of namespaces that no longer have pods", func() {
+			dir := filepath.Join(configDirectory, "allowlist.d")
+			Expect(WriteAllowlistByNamespace(appFs, dir, []AllowlistItem{
+				{Name: "app", Namespace: "team-a", Kind: common.KIND_DEPLOYMENT, Group: "apps"},
+				{Name: "debug", Namespace: "team-b", Kind: common.KIND_POD},
+			})).To(Succeed())
+			Expect(afero.WriteFile(appFs, filepath.Join(dir, "team-c.yaml"), []byte("- {name: agent, namespace: team-c, kind: Pod}"), 0644)).To(Succeed())
+
+			Expect(WriteAllowlistByNamespace(appFs, dir, []AllowlistItem{
+				{Name: "app", Namespace: "team-a", Kind: common.KIND_DEPLOYMENT, Group: "apps"},
+			})).To(Succeed())
+			Expect(afero.Exists(appFs, filepath.Join(dir, "team-a.yaml"))).To(BeTrue())
+			Expect(afero.Exists(appFs, filepath.Join(dir, "team-b.yaml"))).To(BeFalse())
+			Expect(afero.Exists(appFs, filepath.Join(dir, "team-c.yaml"))).To(BeTrue()) // not generated
+		})
+
+		It("allowlist is read by each run, unless it's watched", func() {
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(BeEmpty())
+
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: other, namespace: namespace, kind: Pod}"), 0644)).To(Succeed())
+			Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(HaveLen(1))
+
+			allowedPodsValidator.(common.Watchable).SetWatched(true)
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("[]"), 0644)).To(Succeed())
+			Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(HaveLen(1)) // until reloaded
+		})
+
+		It("the resource index only applies to the next run", func() {
+			Expect(afero.WriteFile(appFs, filepath.Join(configDirectory, allowlistFile), []byte("- {name: app, namespace: namespace, kind: Deployment}"), 0644)).To(Succeed())
+			replicaSet := unstructured.Unstructured{}
+			replicaSet.SetAPIVersion("apps/v1")
+			replicaSet.SetKind(common.KIND_REPLICA_SET)
+			replicaSet.SetNamespace(namespace)
+			replicaSet.SetName("app-1")
+			replicaSet.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_DEPLOYMENT, Name: "app"}})
+			allowedPodUnstructuredResource.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: common.KIND_REPLICA_SET, Name: "app-1"}})
+			resources := []unstructured.Unstructured{replicaSet, allowedPodUnstructuredResource}
+
+			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
+			Expect(err).To(Succeed())
+			allowedPodsValidator.(common.ResourceIndexAware).SetResourceIndex(common.NewResourceIndex(resources))
+			Expect(allowedPodsValidator.Validate(resources)).To(BeEmpty())
+
+			// without the ReplicaSet, the pod's Deployment isn't known
+			Expect(allowedPodsValidator.Validate([]unstructured.Unstructured{allowedPodUnstructuredResource})).To(HaveLen(1))
+		})
+
 		It("allowlist not found", func() {
 			appFs.Remove(filepath.Join(configDirectory, allowlistFile))
 			allowedPodsValidator, err := NewAllowedPodsValidator(ctx, configDirectory)
@@ -143,3 +514,9 @@ var _ = Describe("Allowed Pods", func() {
 		})
 	})
 })
+
+func toUnstructured(object interface{}) unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	Expect(err).To(Succeed())
+	return unstructured.Unstructured{Object: content}
+}
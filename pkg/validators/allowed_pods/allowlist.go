@@ -0,0 +1,168 @@
+package allowed_pods
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the group of an AllowlistItem that matches resources of the core API group (e.g. Pods) only
+const coreGroup = "core"
+
+/*
+allowlistEntry is a parsed AllowlistItem
+*/
+type allowlistEntry struct {
+	item      AllowlistItem
+	origin    string // the file or ConfigMap of the item (see common.ListDocument)
+	position  int    // of the item in its origin, starting at 1
+	trusted   bool   // listed by a file, or by a ConfigMap of a trusted namespace (see common.ListDocument)
+	name      pattern
+	namespace pattern
+	selector  labels.Selector // nil if the item has no selector
+}
+
+/*
+pattern matches names exactly, by a glob (e.g. "backup-*"), or by a regular expression enclosed in slashes (e.g. "/^tenant-[0-9]+$/")
+*/
+type pattern struct {
+	value  string
+	glob   bool
+	regexp *regexp.Regexp
+}
+
+func newPattern(value string) (pattern, error) {
+	if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		compiled, err := regexp.Compile(value[1 : len(value)-1])
+		if err != nil {
+			return pattern{}, fmt.Errorf("invalid regular expression %s: %w", value, err)
+		}
+		return pattern{value: value, regexp: compiled}, nil
+	}
+
+	if strings.ContainsAny(value, "*?[") {
+		if _, err := path.Match(value, ""); err != nil {
+			return pattern{}, fmt.Errorf("invalid pattern %s: %w", value, err)
+		}
+		return pattern{value: value, glob: true}, nil
+	}
+	return pattern{value: value}, nil
+}
+
+func (p pattern) matches(value string) bool {
+	switch {
+	case p.regexp != nil:
+		return p.regexp.MatchString(value)
+	case p.glob:
+		matched, _ := path.Match(p.value, value)
+		return matched
+	default:
+		return p.value == value
+	}
+}
+
+// identifies an allowlistEntry
+type allowlistEntryKey struct {
+	origin   string
+	position int
+}
+
+func newAllowlistEntry(item AllowlistItem, origin string, position int) (allowlistEntry, error) {
+	response := allowlistEntry{item: item, origin: origin, position: position}
+
+	var err error
+	if response.name, err = newPattern(item.Name); err != nil {
+		return response, fmt.Errorf("name: %w", err)
+	}
+	if response.namespace, err = newPattern(item.Namespace); err != nil {
+		return response, fmt.Errorf("namespace: %w", err)
+	}
+	if item.Selector != "" {
+		if response.selector, err = labels.Parse(item.Selector); err != nil {
+			return response, fmt.Errorf("invalid selector %q: %w", item.Selector, err)
+		}
+	}
+	if err = item.Constraints.validate(); err != nil {
+		return response, fmt.Errorf("constraints: %w", err)
+	}
+	return response, nil
+}
+
+func (e allowlistEntry) key() allowlistEntryKey {
+	return allowlistEntryKey{origin: e.origin, position: e.position}
+}
+
+/*
+String describes the entry like a common.ResourceKey, e.g. "Deployment.apps/default/app"
+*/
+func (e allowlistEntry) String() string {
+	kind := e.item.Kind
+	if e.item.Group != "" && e.item.Group != coreGroup {
+		kind += "." + e.item.Group
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, e.item.Namespace, e.item.Name)
+}
+
+/*
+matches returns true if the entry matches a resource of the given kind and API group (see common.ResourceKey).
+Its selector is matched against labels; a reference to a resource, whose labels aren't known, doesn't match entries with selectors.
+*/
+func (e allowlistEntry) matches(kind string, group string, namespace string, name string, resourceLabels map[string]string, labelsKnown bool) bool {
+	if e.item.Kind != kind || !e.matchesGroup(group) || !e.namespace.matches(namespace) || !e.name.matches(name) {
+		return false
+	}
+	if e.selector == nil {
+		return true
+	}
+	return labelsKnown && e.selector.Matches(labels.Set(resourceLabels))
+}
+
+func (e allowlistEntry) matchesGroup(group string) bool {
+	switch e.item.Group {
+	case "":
+		return true
+	case coreGroup:
+		return group == ""
+	default:
+		return e.item.Group == group
+	}
+}
+
+func (e allowlistEntry) matchesResource(resource *unstructured.Unstructured) bool {
+	return e.matches(resource.GetKind(), resource.GroupVersionKind().Group, resource.GetNamespace(), resource.GetName(), resource.GetLabels(), true)
+}
+
+/*
+toUnstructured represents the entry as a resource, which violations of the entry itself (e.g. since it's stale) refer to.
+Entries don't state a version, so "v1" stands in for it; entries of all groups have no apiVersion.
+*/
+func (e allowlistEntry) toUnstructured() unstructured.Unstructured {
+	response := unstructured.Unstructured{}
+	switch e.item.Group {
+	case "":
+	case coreGroup:
+		response.SetAPIVersion("v1")
+	default:
+		response.SetAPIVersion(e.item.Group + "/v1")
+	}
+	response.SetKind(e.item.Kind)
+	response.SetNamespace(e.item.Namespace)
+	response.SetName(e.item.Name)
+	return response
+}
+
+/*
+groupOf returns the API group of an apiVersion, e.g. "apps" of "apps/v1"
+*/
+func groupOf(apiVersion string) string {
+	groupVersion, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return ""
+	}
+	return groupVersion.Group
+}
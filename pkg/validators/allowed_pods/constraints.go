@@ -0,0 +1,159 @@
+package allowed_pods
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	ruleImageNotPermitted          = "image-not-permitted"
+	ruleServiceAccountNotPermitted = "service-account-not-permitted"
+	rulePrivilegedNotPermitted     = "privileged-not-permitted"
+	ruleReplicasExceeded           = "replicas-exceeded"
+
+	defaultServiceAccount = "default"
+)
+
+/*
+AllowlistConstraints restrict what an allowed workload may run, so that a workload that drifts from what was approved is reported.
+Empty fields don't constrain the workload.
+*/
+type AllowlistConstraints struct {
+	/*
+		Images are the permitted images of all containers: a repository (e.g. "registry.example.com/team/app"), which permits all of its tags and digests,
+		optionally with a tag or digest (e.g. "registry.example.com/team/app@sha256:..."), which permits that tag or digest only.
+		Repositories may be globs, e.g. "registry.example.com/team/*". Images are compared as written in the pod spec, i.e. without registry defaults.
+	*/
+	Images         []string `yaml:"images"`
+	ServiceAccount string   `yaml:"serviceAccount"` // the permitted service account name
+	MaxReplicas    *int64   `yaml:"maxReplicas"`    // the maximum spec.replicas of the allowed workload, if it was fetched
+	Privileged     *bool    `yaml:"privileged"`     // whether privileged containers (securityContext.privileged) are permitted
+}
+
+/*
+allowlistMatch is an allowlist entry that allows a pod, by matching the pod or one of its owners
+*/
+type allowlistMatch struct {
+	entry    allowlistEntry
+	resource *unstructured.Unstructured // the matched pod or owner; nil if the owner is only known by its owner reference
+}
+
+func (c *AllowlistConstraints) validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, image := range c.Images {
+		repository, _, _ := splitImage(image)
+		if _, err := path.Match(repository, ""); err != nil {
+			return fmt.Errorf("invalid image %s: %w", image, err)
+		}
+	}
+	if c.MaxReplicas != nil && *c.MaxReplicas < 0 {
+		return fmt.Errorf("maxReplicas must not be negative")
+	}
+	return nil
+}
+
+/*
+check returns the violations of the pod (and of the matched workload's replicas) against the constraints of the match
+*/
+func (m allowlistMatch) check(pod *unstructured.Unstructured) ([]common.Violation, error) {
+	constraints := m.entry.item.Constraints
+	if constraints == nil {
+		return nil, nil
+	}
+
+	var spec corev1.Pod
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(pod.Object, &spec); err != nil {
+		return nil, fmt.Errorf("couldn't check the allowlist constraints of %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+
+	var violations []common.Violation
+	newViolation := func(resource unstructured.Unstructured, ruleID string, message string, fieldPath string) common.Violation {
+		return common.NewViolation(resource, fmt.Sprintf("%s, which the allowlist entry %s doesn't permit", message, m.entry), common.SeverityHigh.Level(), ValidatorName).
+			WithRuleID(ruleID).
+			WithFieldPath(fieldPath).
+			WithRemediation(fmt.Sprintf("revert the change, or update the constraints of the entry in %s", allowlistFile)).
+			WithDocumentationURL(documentationURL).
+			WithDetail("allowlistEntry", m.entry.String())
+	}
+
+	forEachContainer(spec, func(containersPath string, i int, container corev1.Container) {
+		if len(constraints.Images) > 0 && !imagePermitted(constraints.Images, container.Image) {
+			violations = append(violations, newViolation(*pod, ruleImageNotPermitted, fmt.Sprintf("container %s runs image %s", container.Name, container.Image),
+				fmt.Sprintf("%s[%d].image", containersPath, i)).WithContainerName(container.Name))
+		}
+		if constraints.Privileged != nil && !*constraints.Privileged && container.SecurityContext != nil &&
+			container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			violations = append(violations, newViolation(*pod, rulePrivilegedNotPermitted, fmt.Sprintf("container %s is privileged", container.Name),
+				fmt.Sprintf("%s[%d].securityContext.privileged", containersPath, i)).WithContainerName(container.Name))
+		}
+	})
+
+	if constraints.ServiceAccount != "" {
+		serviceAccount := spec.Spec.ServiceAccountName
+		if serviceAccount == "" {
+			serviceAccount = defaultServiceAccount
+		}
+		if serviceAccount != constraints.ServiceAccount {
+			violations = append(violations, newViolation(*pod, ruleServiceAccountNotPermitted, fmt.Sprintf("the pod runs as service account %s", serviceAccount), "spec.serviceAccountName"))
+		}
+	}
+
+	if constraints.MaxReplicas != nil && m.resource != nil {
+		replicas, found, _ := unstructured.NestedInt64(m.resource.Object, "spec", "replicas")
+		if found && replicas > *constraints.MaxReplicas {
+			violations = append(violations, newViolation(*m.resource, ruleReplicasExceeded, fmt.Sprintf("%d replicas exceed the maximum of %d", replicas, *constraints.MaxReplicas), "spec.replicas"))
+		}
+	}
+
+	return violations, nil
+}
+
+func forEachContainer(pod corev1.Pod, f func(containersPath string, i int, container corev1.Container)) {
+	for i, container := range pod.Spec.InitContainers {
+		f("spec.initContainers", i, container)
+	}
+	for i, container := range pod.Spec.Containers {
+		f("spec.containers", i, container)
+	}
+	for i, container := range pod.Spec.EphemeralContainers {
+		f("spec.ephemeralContainers", i, corev1.Container(container.EphemeralContainerCommon))
+	}
+}
+
+func imagePermitted(permitted []string, image string) bool {
+	repository, tag, digest := splitImage(image)
+	for _, permittedImage := range permitted {
+		permittedRepository, permittedTag, permittedDigest := splitImage(permittedImage)
+		if matched, _ := path.Match(permittedRepository, repository); !matched {
+			continue
+		}
+		if (permittedTag == "" || permittedTag == tag) && (permittedDigest == "" || permittedDigest == digest) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+splitImage splits an image reference into its repository, tag and digest, e.g. "registry:5000/app:1.0@sha256:..."
+*/
+func splitImage(image string) (repository string, tag string, digest string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	// a colon after the last slash separates the tag; others separate the registry's port
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
@@ -0,0 +1,155 @@
+package allowed_pods
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/SAP/k8s-resource-validator/pkg/common"
+)
+
+const (
+	// the name of the file of generated items of cluster-scoped resources (underscores aren't valid in namespace names)
+	clusterScopedFileName = "_cluster.yaml"
+	// the first line of generated files, which tells them apart from hand-written ones
+	generatedHeader = "# generated by k8s-resource-validator\n"
+)
+
+/*
+GenerateAllowlist returns an allowlist that allows all the indexed pods, e.g. in order to bootstrap allowlist.yaml for an existing cluster.
+Each pod is allowed by its top-level controller, which is found by following the controller owner references (or the first owner reference,
+if there's no controller) of the pod and its owners. An owner that wasn't fetched is allowed by its owner reference instead.
+
+Items are exact (without patterns, selectors or constraints), unique, and sorted by namespace, kind and name.
+*/
+func GenerateAllowlist(index *common.ResourceIndex) []AllowlistItem {
+	var response []AllowlistItem
+	for _, pod := range index.Resources() {
+		if pod.GetKind() != common.KIND_POD {
+			continue
+		}
+		item := topLevelItem(index, &pod)
+		if !slices.Contains(response, item) {
+			response = append(response, item)
+		}
+	}
+
+	slices.SortFunc(response, func(a, b AllowlistItem) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+	})
+	return response
+}
+
+/*
+GroupAllowlistByNamespace groups allowlist items by their namespace; items of cluster-scoped resources are grouped by ""
+*/
+func GroupAllowlistByNamespace(items []AllowlistItem) map[string][]AllowlistItem {
+	response := make(map[string][]AllowlistItem)
+	for _, item := range items {
+		response[item.Namespace] = append(response[item.Namespace], item)
+	}
+	return response
+}
+
+/*
+WriteAllowlist writes items to path as YAML (e.g. to allowlist.yaml), replacing the file if it exists.
+The file starts with a comment that marks it as generated.
+*/
+func WriteAllowlist(appFs afero.Fs, path string, items []AllowlistItem) error {
+	content, err := yaml.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(appFs, path, append([]byte(generatedHeader), content...), 0644)
+}
+
+/*
+WriteAllowlistByNamespace writes the items of each namespace to <namespace>.yaml in dir (and those of cluster-scoped resources to _cluster.yaml),
+e.g. to allowlist.d/, or in order to hand them over to the teams that own the namespaces.
+
+Generated files in dir that weren't written again (e.g. of namespaces that no longer have pods) are removed; other files are kept.
+*/
+func WriteAllowlistByNamespace(appFs afero.Fs, dir string, items []AllowlistItem) error {
+	if err := appFs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	written := map[string]bool{}
+	for namespace, namespaceItems := range GroupAllowlistByNamespace(items) {
+		fileName := clusterScopedFileName
+		if namespace != "" {
+			fileName = namespace + ".yaml"
+		}
+		if err := WriteAllowlist(appFs, filepath.Join(dir, fileName), namespaceItems); err != nil {
+			return fmt.Errorf("couldn't write the allowlist of namespace %q: %w", namespace, err)
+		}
+		written[fileName] = true
+	}
+	return removeGeneratedFiles(appFs, dir, written)
+}
+
+/*
+removeGeneratedFiles removes the generated YAML files in dir (see generatedHeader), except for those in keep
+*/
+func removeGeneratedFiles(appFs afero.Fs, dir string, keep map[string]bool) error {
+	entries, err := afero.ReadDir(appFs, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || keep[entry.Name()] || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, err := afero.ReadFile(appFs, path)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(content, []byte(generatedHeader)) {
+			continue
+		}
+		if err := appFs.Remove(path); err != nil {
+			return fmt.Errorf("couldn't remove the generated allowlist %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+/*
+topLevelItem returns the allowlist item of the resource's top-level controller (see GenerateAllowlist)
+*/
+func topLevelItem(index *common.ResourceIndex, resource *unstructured.Unstructured) AllowlistItem {
+	visited := map[*unstructured.Unstructured]bool{}
+	for !visited[resource] {
+		visited[resource] = true
+
+		references := resource.GetOwnerReferences()
+		if len(references) == 0 {
+			break
+		}
+		reference := references[0]
+		if i := slices.IndexFunc(references, func(reference metav1.OwnerReference) bool {
+			return reference.Controller != nil && *reference.Controller
+		}); i >= 0 {
+			reference = references[i]
+		}
+
+		owner, found := index.Owner(resource, reference)
+		if !found {
+			// allowlistMatches matches the reference in the namespace of the dependent
+			return newAllowlistItem(reference.Kind, groupOf(reference.APIVersion), resource.GetNamespace(), reference.Name)
+		}
+		resource = owner
+	}
+	return newAllowlistItem(resource.GetKind(), resource.GroupVersionKind().Group, resource.GetNamespace(), resource.GetName())
+}
+
+func newAllowlistItem(kind string, group string, namespace string, name string) AllowlistItem {
+	return AllowlistItem{Name: name, Namespace: namespace, Kind: kind, Group: group}
+}
@@ -49,7 +49,9 @@ func (v *FakeValidator) Validate(resources []unstructured.Unstructured) (violati
 		resource.SetName(fmt.Sprintf("%d", i))
 		resource.SetNamespace("fake")
 		resource.SetKind("Fake")
-		violation := common.NewViolation(resource, "Fake resource violation", 1, ValidatorName)
+		violation := common.NewViolation(resource, "Fake resource violation", common.SeverityHigh.Level(), ValidatorName).
+			WithRuleID("fake").
+			WithRemediation("none; this violation is for testing only")
 		violations = append(violations, violation)
 	}
 
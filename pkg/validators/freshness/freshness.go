@@ -3,6 +3,7 @@ package freshness
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -12,10 +13,15 @@ import (
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 )
 
-const ValidatorName = "built-in:freshness"
+const (
+	ValidatorName = "built-in:freshness"
+
+	ruleStalePod     = "stale-pod"
+	documentationURL = common.DocumentationURL + "#freshness"
+)
 
 func NewFreshnessValidator(ctx context.Context, freshnessThresholdInHours int32) (common.Validator, error) {
-	response := FreshnessValidator{freshnessThresholdInHours: freshnessThresholdInHours, ctx: ctx}
+	response := FreshnessValidator{freshnessThresholdInHours: freshnessThresholdInHours, ctx: ctx, config: common.NewConfig()}
 
 	var err error
 	response.logger, err = logr.FromContext(ctx)
@@ -28,6 +34,7 @@ func NewFreshnessValidator(ctx context.Context, freshnessThresholdInHours int32)
 
 type FreshnessValidator struct {
 	freshnessThresholdInHours int32
+	config                    common.Config // pods with its exempt label are skipped; see SetConfig()
 	ctx                       context.Context
 	logger                    logr.Logger
 }
@@ -36,6 +43,13 @@ func (v *FreshnessValidator) GetName() string {
 	return ValidatorName
 }
 
+/*
+SetConfig sets the configuration, whose exempt label the validator skips pods with
+*/
+func (v *FreshnessValidator) SetConfig(config common.Config) {
+	v.config = config
+}
+
 /*
 *
  */
@@ -43,16 +57,24 @@ func (v *FreshnessValidator) Validate(resources []unstructured.Unstructured) (vi
 	pods := common.GetPods(resources)
 
 	for _, p := range pods {
-		if common.IsExempt(p) {
+		if v.config.IsExempt(p) {
 			v.logger.V(2).Info(fmt.Sprint("is exempt from checking for freshness:", p.GetNamespace(), p.GetName()))
+			continue
+		}
+
+		podIsStale := isPodStale(p, v.freshnessThresholdInHours)
+		if podIsStale {
+			age := metav1.Now().Sub(p.GetCreationTimestamp().Time)
+			violation := common.NewViolation(p, "Pod is stale", common.SeverityHigh.Level(), ValidatorName).
+				WithRuleID(ruleStalePod).
+				WithFieldPath("metadata.creationTimestamp").
+				WithRemediation("recreate the pod (e.g. by restarting its workload), so that it runs with current images and configuration").
+				WithDocumentationURL(documentationURL).
+				WithDetail("age", age.Round(time.Minute).String()).
+				WithDetail("thresholdInHours", strconv.Itoa(int(v.freshnessThresholdInHours)))
+			violations = append(violations, violation)
 		} else {
-			podIsStale := isPodStale(p, v.freshnessThresholdInHours)
-			if podIsStale {
-				violation := common.NewViolation(p, "Pod is stale", 1, ValidatorName)
-				violations = append(violations, violation)
-			} else {
-				v.logger.V(3).Info(fmt.Sprintf("pod is fresh: %s/%s", p.GetNamespace(), p.GetName()))
-			}
+			v.logger.V(3).Info(fmt.Sprintf("pod is fresh: %s/%s", p.GetNamespace(), p.GetName()))
 		}
 	}
 
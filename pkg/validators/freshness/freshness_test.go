@@ -81,13 +81,15 @@ var _ = Describe("Freshness", func() {
 			violationsArray, err := freshnessValidator.Validate([]unstructured.Unstructured{freshnessUnstructuredResource})
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleStalePod))
+			Expect(violationsArray[0].FieldPath).To(Equal("metadata.creationTimestamp"))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("age", "1h30m0s"))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("thresholdInHours", "1"))
 		})
 
 		It("pod is stale, but is exempt", func() {
-			common.ExemptPodLabelName = "label1"
-			common.ExemptPodLabelValue = "exempt"
 			var labels map[string]string = make(map[string]string)
-			labels["label1"] = "exempt"
+			labels[common.NewConfig().Exempt.LabelName] = common.NewConfig().Exempt.LabelValue
 
 			freshnessValidator, err := NewFreshnessValidator(ctx, 1) // any resource created less than 1 hour ago is fresh
 			Expect(err).To(Succeed())
@@ -102,5 +104,26 @@ var _ = Describe("Freshness", func() {
 			Expect(violationsArray).To(HaveLen(0))
 		})
 
+		It("the configured exempt label replaces the default one", func() {
+			config := common.NewConfig()
+			config.Exempt.LabelName = "team"
+			config.Exempt.LabelValue = "platform"
+
+			freshnessValidator, err := NewFreshnessValidator(ctx, 1)
+			Expect(err).To(Succeed())
+			freshnessValidator.(common.ConfigAware).SetConfig(config)
+
+			freshnessUnstructuredResource.SetCreationTimestamp(metav1.NewTime(metav1.Now().Add(time.Minute * -90)))
+			freshnessUnstructuredResource.SetLabels(map[string]string{common.NewConfig().Exempt.LabelName: common.NewConfig().Exempt.LabelValue})
+			violationsArray, err := freshnessValidator.Validate([]unstructured.Unstructured{freshnessUnstructuredResource})
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(HaveLen(1))
+
+			freshnessUnstructuredResource.SetLabels(map[string]string{"team": "platform"})
+			violationsArray, err = freshnessValidator.Validate([]unstructured.Unstructured{freshnessUnstructuredResource})
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(BeEmpty())
+		})
+
 	})
 })
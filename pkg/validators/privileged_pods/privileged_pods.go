@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 	"github.com/go-logr/logr"
@@ -17,16 +18,29 @@ var (
 	excessiveCapabilities      = []string{capabilityCapSysAdmin}
 	errPodResource             = errors.New("object is not type pod")
 	errUnstructuredPodResource = errors.New("unstructured pod resource is nil")
+
+	remediations = map[string]string{
+		rulePrivileged:            "set securityContext.privileged to false",
+		rulePrivilegeEscalation:   "set securityContext.allowPrivilegeEscalation to false",
+		ruleUnmaskedProcMount:     "remove securityContext.procMount, or set it to Default",
+		ruleExcessiveCapabilities: "remove the capability from securityContext.capabilities.add",
+	}
 )
 
 const (
 	ValidatorName         = "built-in:privileged-pods"
 	capabilityCapSysAdmin = "CAP_SYS_ADMIN"
 	privilegedReasonTpl   = "securityContext %s value is %s\n"
+	documentationURL      = common.DocumentationURL + "#privileged-pods"
+
+	rulePrivileged            = "privileged"
+	rulePrivilegeEscalation   = "allow-privilege-escalation"
+	ruleUnmaskedProcMount     = "unmasked-proc-mount"
+	ruleExcessiveCapabilities = "excessive-capabilities"
 )
 
 func NewPrivilegedPodsValidator(ctx context.Context) (common.Validator, error) {
-	response := PrivilegedPodsValidator{ctx: ctx}
+	response := PrivilegedPodsValidator{ctx: ctx, config: common.NewConfig()}
 	
 	var err error
 	response.logger, err = logr.FromContext(ctx)
@@ -39,6 +53,7 @@ func NewPrivilegedPodsValidator(ctx context.Context) (common.Validator, error) {
 
 type PrivilegedPodsValidator struct {
 	preApprovedPods []unstructured.Unstructured
+	config          common.Config // pods with its exempt label are skipped; see SetConfig()
 	ctx             context.Context
 	logger          logr.Logger
 }
@@ -47,6 +62,13 @@ func (v *PrivilegedPodsValidator) GetName() string {
 	return ValidatorName
 }
 
+/*
+SetConfig sets the configuration, whose exempt label the validator skips pods with
+*/
+func (v *PrivilegedPodsValidator) SetConfig(config common.Config) {
+	v.config = config
+}
+
 /*
 *
 
@@ -58,7 +80,7 @@ func (v *PrivilegedPodsValidator) Validate(resources []unstructured.Unstructured
 	var violations []common.Violation
 	for _, pod := range pods {
 		namespace, name := pod.GetNamespace(), pod.GetName()
-		if common.IsExempt(pod) {
+		if v.config.IsExempt(pod) {
 			v.logger.V(2).Info(fmt.Sprintf("is exempt: %s/%s", namespace, name))
 			continue
 		}
@@ -85,18 +107,50 @@ func (v *PrivilegedPodsValidator) SetPreApprovedPods(pods []unstructured.Unstruc
 }
 
 func (v *PrivilegedPodsValidator) handlePrivilegedPod(p unstructured.Unstructured, violations []common.Violation, namespace, name string) ([]common.Violation, error) {
-	privilegedPodMsg, err := isPrivilegedPod(p)
-	if err != nil {
+	var pod v1.Pod
+	if err := createPodFromUnstructuredResource(p, &pod); err != nil {
 		return nil, err
 	}
 
-	if privilegedPodMsg != "" {
-		violation := common.NewViolation(p, "found privileged pod", 1, ValidatorName)
+	// a single violation per pod, which describes the first finding (see doesPrivilegedContainerExist)
+	findings := privilegedFindings(pod)
+	if len(findings) > 0 {
+		finding := findings[0]
+		v.logger.V(2).Info(fmt.Sprintf("found privileged container %s in %s/%s: %s", finding.container, namespace, name, strings.TrimSpace(finding.reason())))
+		violation := common.NewViolation(p, "found privileged pod", common.SeverityHigh.Level(), ValidatorName).
+			WithRuleID(finding.ruleID).
+			WithFieldPath(finding.fieldPath).
+			WithContainerName(finding.container).
+			WithRemediation(remediations[finding.ruleID]).
+			WithDocumentationURL(documentationURL).
+			WithDetail("value", finding.value).
+			WithDetail("reason", strings.ReplaceAll(strings.TrimSpace(reasons(findings)), "\n", "; "))
 		violations = append(violations, violation)
 	}
 	return violations, nil
 }
 
+// privilegedFinding describes a container's security context setting that may let the pod run in privileged mode
+type privilegedFinding struct {
+	ruleID    string
+	setting   string // the setting's name, as written in the reason
+	value     string
+	container string
+	fieldPath string // the setting's path; relative to the security context until the container is known
+}
+
+func (f privilegedFinding) reason() string {
+	return fmt.Sprintf(privilegedReasonTpl, f.setting, f.value)
+}
+
+func reasons(findings []privilegedFinding) string {
+	var response string
+	for _, finding := range findings {
+		response += finding.reason()
+	}
+	return response
+}
+
 // isPrivilegedPod function convert a resource interface to a v1.Pod object and creates a message for suspicious configurations
 // in pod's containers security context which can lead the pod to run with privileged mode. (message is empty in case of there is no privileged pod)
 // it ignores pods that belongs to the allowlist.
@@ -137,63 +191,101 @@ func validateObjectPod(obj interface{}) error {
 
 // doesPrivilegedContainerExist gets a pod and validates each of its container types, returns a message according search findings
 func doesPrivilegedContainerExist(pod v1.Pod) string {
-	containersTypeContainer := append(pod.Spec.InitContainers, pod.Spec.Containers...)
-	privilegedReason := foundPrivilegedVulnerabilityContainer(containersTypeContainer) + foundPrivilegedVulnerabilityContainer(pod.Spec.EphemeralContainers)
-	return privilegedReason
+	return reasons(privilegedFindings(pod))
+}
+
+// privilegedFindings returns the first finding of the pod's init and app containers, and the first finding of its ephemeral containers
+func privilegedFindings(pod v1.Pod) []privilegedFinding {
+	var findings []privilegedFinding
+	finding := containersFinding(pod.Spec.InitContainers, "spec.initContainers")
+	if finding == nil {
+		finding = containersFinding(pod.Spec.Containers, "spec.containers")
+	}
+	if finding != nil {
+		findings = append(findings, *finding)
+	}
+	if finding := containersFinding(pod.Spec.EphemeralContainers, "spec.ephemeralContainers"); finding != nil {
+		findings = append(findings, *finding)
+	}
+	return findings
 }
 
 func foundPrivilegedVulnerabilityContainer(containersInput interface{}) string {
+	if finding := containersFinding(containersInput, ""); finding != nil {
+		return finding.reason()
+	}
+	return ""
+}
+
+// containersFinding returns the first finding of the containers, or nil.
+// containersPath is the path of the containers within the pod, e.g. "spec.containers"
+func containersFinding(containersInput interface{}, containersPath string) *privilegedFinding {
+	containerFinding := func(index int, name string, securityContext *v1.SecurityContext) *privilegedFinding {
+		finding := securityContextFinding(securityContext)
+		if finding != nil {
+			finding.container = name
+			finding.fieldPath = fmt.Sprintf("%s[%d].securityContext.%s", containersPath, index, finding.fieldPath)
+		}
+		return finding
+	}
+
 	switch containers := containersInput.(type) {
 	case []v1.Container:
-		for _, container := range containers {
-			privilegedReason := foundSecurityContextPrivilegedVulnerability(container.SecurityContext)
-			if privilegedReason != "" {
-				return privilegedReason
+		for i, container := range containers {
+			if finding := containerFinding(i, container.Name, container.SecurityContext); finding != nil {
+				return finding
 			}
 		}
 	case []v1.EphemeralContainer:
-		for _, container := range containers {
-			privilegedReason := foundSecurityContextPrivilegedVulnerability(container.SecurityContext)
-			if privilegedReason != "" {
-				return privilegedReason
+		for i, container := range containers {
+			if finding := containerFinding(i, container.Name, container.SecurityContext); finding != nil {
+				return finding
 			}
 		}
 	}
 
-	return ""
+	return nil
 }
 
 // foundSecurityContextPrivilegedVulnerability gets a container's security context and checks for excessive permissions of configurations.
 // returns reason for privileged notification.
 func foundSecurityContextPrivilegedVulnerability(securityContext *v1.SecurityContext) string {
+	if finding := securityContextFinding(securityContext); finding != nil {
+		return finding.reason()
+	}
+	return ""
+}
+
+// securityContextFinding returns the finding of foundSecurityContextPrivilegedVulnerability, or nil
+func securityContextFinding(securityContext *v1.SecurityContext) *privilegedFinding {
 	if securityContext == nil {
-		return ""
+		return nil
 	}
 
 	// Check if ProcMount is set to UnmaskedProcMount
 
 	if securityContext.ProcMount != nil && *securityContext.ProcMount == v1.UnmaskedProcMount {
-		return fmt.Sprintf(privilegedReasonTpl, "ProcMount", "Unmasked")
+		return &privilegedFinding{ruleID: ruleUnmaskedProcMount, setting: "ProcMount", value: "Unmasked", fieldPath: "procMount"}
 	}
 
 	// Check if AllowPrivilegeEscalation is true
 	if securityContext.AllowPrivilegeEscalation != nil && *securityContext.AllowPrivilegeEscalation {
-		return fmt.Sprintf(privilegedReasonTpl, "AllowPrivilegeEscalation", "true")
+		return &privilegedFinding{ruleID: rulePrivilegeEscalation, setting: "AllowPrivilegeEscalation", value: "true", fieldPath: "allowPrivilegeEscalation"}
 	}
 
 	// Check if Privileged is true
 	if securityContext.Privileged != nil && *securityContext.Privileged {
-		return fmt.Sprintf(privilegedReasonTpl, "Privileged", "true")
+		return &privilegedFinding{ruleID: rulePrivileged, setting: "Privileged", value: "true", fieldPath: "privileged"}
 	}
 
 	// Check if any of the capabilities in the Add slice are in the excessiveCapabilities slice
 	if securityContext.Capabilities != nil {
-		for _, capability := range securityContext.Capabilities.Add {
+		for i, capability := range securityContext.Capabilities.Add {
 			if slices.Contains(excessiveCapabilities, string(capability)) {
-				return fmt.Sprintf(privilegedReasonTpl, "capabilities", string(capability))
+				return &privilegedFinding{ruleID: ruleExcessiveCapabilities, setting: "capabilities", value: string(capability), fieldPath: fmt.Sprintf("capabilities.add[%d]", i)}
 			}
 		}
 	}
-	// If none of the checks pass, return empty string
-	return ""
+	// If none of the checks pass, return nil
+	return nil
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/afero"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
@@ -201,6 +202,35 @@ var _ = Describe("Privileged Pods", func() {
 			Expect(violationsArray[0].Level).To(Equal(1))
 		})
 
+		It("describes the first violating container", func() {
+			privilegedPodsValidator, err := NewPrivilegedPodsValidator(ctx)
+			Expect(err).To(Succeed())
+			pod := corev1.Pod{Spec: corev1.PodSpec{
+				Containers: append(append([]corev1.Container{}, notPrivilegedContainer...), corev1.Container{
+					Name:            "sidecar",
+					SecurityContext: excessiveCapabilitySecurityContext,
+				}),
+				EphemeralContainers: privilegedRiskEphemeralContainer,
+			}}
+			pod.SetName("multi-container-pod")
+			pod.SetNamespace(podNamespace)
+			pod.Kind = common.KIND_POD
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&pod)
+			Expect(err).To(Succeed())
+
+			violationsArray, err := privilegedPodsValidator.Validate([]unstructured.Unstructured{{Object: content}})
+			Expect(err).To(Succeed())
+			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].Message).To(Equal("found privileged pod"))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleExcessiveCapabilities))
+			Expect(violationsArray[0].FieldPath).To(Equal("spec.containers[1].securityContext.capabilities.add[0]"))
+			Expect(violationsArray[0].ContainerName).To(Equal("sidecar"))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("value", "CAP_SYS_ADMIN"))
+			Expect(violationsArray[0].Details).To(HaveKeyWithValue("reason", "securityContext capabilities value is CAP_SYS_ADMIN; securityContext Privileged value is true"))
+			Expect(violationsArray[0].Remediation).NotTo(BeEmpty())
+			Expect(violationsArray[0].DocumentationURL).NotTo(BeEmpty())
+		})
+
 		It("ignores privileged pods that belongs to allow list - returns nil and empty Errors array", func() {
 			privilegedPodsValidator, err := NewPrivilegedPodsValidator(ctx)
 			Expect(err).To(Succeed())
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 )
@@ -17,6 +21,11 @@ import (
 const (
 	ValidatorName     = "built-in:readiness"
 	readinesslistFile = "readinesslist.yaml"
+
+	ruleResourceNotFound = "resource-not-found"
+	ruleResourceNotReady = "resource-not-ready"
+	ruleInvalidConfigMap = "invalid-readinesslist-configmap" // see common.ListDocument.NewViolation()
+	documentationURL     = common.DocumentationURL + "#readiness"
 )
 
 type ReadinesslistItem struct {
@@ -44,20 +53,77 @@ type ReadinessValidator struct {
 	configDir              string
 	logger                 logr.Logger
 	ignoreMissingResources bool
+	readinesslist          atomic.Pointer[[]ReadinesslistItem] // nil until readinesslist.yaml is read by a run (or by Reload())
+	watched                atomic.Bool                         // reloaded by a watcher, rather than read by each run
+	configMaps             common.ListConfigMapsConfig         // see common.ReadinessConfig
+	clientSet              kubernetes.Interface                // reads the ConfigMaps
 }
 
 func (v *ReadinessValidator) GetName() string {
 	return ValidatorName
 }
 
+/*
+SetConfig applies the readiness section of the configuration
+*/
+func (v *ReadinessValidator) SetConfig(config common.Config) {
+	v.configMaps = config.Readiness.ConfigMaps
+}
+
+/*
+SetClientSet sets the client, which the readinesslist ConfigMaps are read with
+*/
+func (v *ReadinessValidator) SetClientSet(clientSet kubernetes.Interface) {
+	v.clientSet = clientSet
+}
+
+/*
+WatchedFiles returns the paths of readinesslist.yaml and of the files in readinesslist.d/
+*/
+func (v *ReadinessValidator) WatchedFiles() []string {
+	paths, err := common.ListFiles(v.appFs, v.configDir, readinesslistFile)
+	if err != nil || !slices.Contains(paths, filepath.Join(v.configDir, readinesslistFile)) {
+		paths = append([]string{filepath.Join(v.configDir, readinesslistFile)}, paths...)
+	}
+	return paths
+}
+
+/*
+SetWatched sets whether a watcher reloads readinesslist.yaml and the files in readinesslist.d/ when they change (see reload.NewWatcher).
+Otherwise, each run reads them.
+*/
+func (v *ReadinessValidator) SetWatched(watched bool) {
+	v.watched.Store(watched)
+}
+
+/*
+Reload reads readinesslist.yaml and the files in readinesslist.d/, and replaces the readinesslist of subsequent runs if they're valid.
+The readinesslist ConfigMaps are read by each run instead.
+*/
+func (v *ReadinessValidator) Reload() error {
+	readinesslist, err := v.readReadinesslist(v.configDir)
+	if err != nil {
+		return err
+	}
+
+	v.readinesslist.Store(&readinesslist)
+	return nil
+}
+
 // validates all the resources from readinesslist are ready
 func (v *ReadinessValidator) Validate(resources []unstructured.Unstructured) ([]common.Violation, error) {
-	var violations []common.Violation
-	var readinesslist []ReadinesslistItem
-	readinesslist, err := v.readReadinesslist(v.configDir)
+	if !v.watched.Load() || v.readinesslist.Load() == nil {
+		if err := v.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	readinesslist := *v.readinesslist.Load()
+
+	configMapItems, violations, err := v.readConfigMaps()
 	if err != nil {
 		return nil, err
 	}
+	readinesslist = append(slices.Clip(readinesslist), configMapItems...)
 
 	var cumulativeErr error
 
@@ -67,7 +133,10 @@ func (v *ReadinessValidator) Validate(resources []unstructured.Unstructured) ([]
 			if v.ignoreMissingResources {
 				v.logger.V(2).Info("could not find readinesslist item, but set to ignore")
 			} else {
-				violation := common.NewViolation(*resource, "readiness violation", 1, ValidatorName)
+				violation := common.NewViolation(*resource, "readiness violation", common.SeverityHigh.Level(), ValidatorName).
+					WithRuleID(ruleResourceNotFound).
+					WithRemediation(fmt.Sprintf("make sure the resource exists, or remove it from %s", readinesslistFile)).
+					WithDocumentationURL(documentationURL)
 				violations = append(violations, violation)
 			}
 			continue
@@ -87,8 +156,7 @@ func (v *ReadinessValidator) Validate(resources []unstructured.Unstructured) ([]
 			v.logger.V(2).Info(fmt.Sprintf("resource Kind: %s Name: %s Namespace: %s is ready",
 				resource.GetKind(), resource.GetName(), resource.GetNamespace()))
 		} else {
-			violation := common.NewViolation(*resource, "readiness violation", 1, ValidatorName)
-			violations = append(violations, violation)
+			violations = append(violations, newNotReadyViolation(resource))
 		}
 	}
 
@@ -96,22 +164,78 @@ func (v *ReadinessValidator) Validate(resources []unstructured.Unstructured) ([]
 }
 
 func (v *ReadinessValidator) readReadinesslist(dir string) ([]ReadinesslistItem, error) {
-	readinesslistFileFullPath := filepath.Join(dir, readinesslistFile)
-	var readinesslist []ReadinesslistItem
-
-	content, err := afero.ReadFile(v.appFs, readinesslistFileFullPath)
+	documents, err := common.ReadListFiles(v.appFs, dir, readinesslistFile)
 	if err != nil {
-		v.logger.Error(err, "couldn't find readinesslist file", readinesslistFileFullPath)
+		v.logger.Error(err, "couldn't find readinesslist file")
 		return nil, err
-	} else {
-		err := yaml.Unmarshal(content, &readinesslist)
+	}
+
+	var response []ReadinesslistItem
+	for _, document := range documents {
+		items, err := parseReadinesslist(document)
 		if err != nil {
-			v.logger.Error(err, "couldn't parse readinesslist file")
+			v.logger.Error(err, "invalid readinesslist file")
 			return nil, err
 		}
+		response = append(response, items...)
 	}
+	return response, nil
+}
 
-	return readinesslist, nil
+/*
+readConfigMaps reads the readinesslist items of the ConfigMaps selected by the configuration.
+Problems of a ConfigMap (e.g. items of namespaces that it doesn't own) are reported as violations of the ConfigMap,
+rather than failing the run, and its offending items are ignored.
+*/
+func (v *ReadinessValidator) readConfigMaps() ([]ReadinesslistItem, []common.Violation, error) {
+	documents, err := common.ReadListConfigMaps(v.ctx, v.clientSet, v.configMaps, readinesslistFile)
+	if err != nil {
+		v.logger.Error(err, "couldn't read readinesslist ConfigMaps")
+		return nil, nil, err
+	}
+
+	var items []ReadinesslistItem
+	var violations []common.Violation
+	for _, document := range documents {
+		parsed, err := parseReadinesslist(document)
+		if err != nil {
+			v.logger.Error(err, "invalid readinesslist ConfigMap")
+			violations = append(violations, document.NewViolation(ValidatorName, ruleInvalidConfigMap, err.Error(),
+				"fix the readinesslist in the ConfigMap; none of its items apply until then").
+				WithDocumentationURL(documentationURL))
+			continue
+		}
+
+		for i, item := range parsed {
+			if !document.Owns(item.Namespace) {
+				violations = append(violations, document.NewViolation(ValidatorName, common.RuleNamespaceNotOwned,
+					fmt.Sprintf("the readinesslist item %s/%s/%s is ignored, since the ConfigMap may only list resources of namespace %s", item.Kind, item.Namespace, item.Name, document.Owner),
+					"move the item to a ConfigMap in the item's namespace").
+					WithDocumentationURL(documentationURL).
+					WithDetail("item", strconv.Itoa(i+1)))
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return items, violations, nil
+}
+
+/*
+parseReadinesslist parses a document of the readinesslist, e.g. readinesslist.yaml
+*/
+func parseReadinesslist(document common.ListDocument) ([]ReadinesslistItem, error) {
+	var items []ReadinesslistItem
+	if err := yaml.Unmarshal(document.Content, &items); err != nil {
+		return nil, fmt.Errorf("%s: %w", document.Origin, err)
+	}
+
+	for i, item := range items {
+		if item.Name == "" || item.Kind == "" {
+			return nil, fmt.Errorf("%s: item %d: name and kind are required", document.Origin, i+1)
+		}
+	}
+	return items, nil
 }
 
 func getReadinesslistItemResource(resources []unstructured.Unstructured, readinesslistItem ReadinesslistItem) (*unstructured.Unstructured, bool) {
@@ -139,6 +263,36 @@ func getReadinesslistItemResource(resources []unstructured.Unstructured, readine
 	return &resource, false
 }
 
+// newNotReadyViolation points to the status field that readiness was determined from, and carries the Ready condition's reason and message, if any
+func newNotReadyViolation(resource *unstructured.Unstructured) common.Violation {
+	violation := common.NewViolation(*resource, "readiness violation", common.SeverityHigh.Level(), ValidatorName).
+		WithRuleID(ruleResourceNotReady).
+		WithRemediation("check the resource's status and events to find out why it isn't ready").
+		WithDocumentationURL(documentationURL)
+
+	conditions, conditionsFound, _ := unstructured.NestedSlice(resource.Object, "status", "conditions")
+	if !conditionsFound {
+		return violation.WithFieldPath("status.ready")
+	}
+
+	violation = violation.WithFieldPath("status.conditions")
+	for i, condition := range conditions {
+		conditionAsMap, ok := condition.(map[string]interface{})
+		if !ok || conditionAsMap["type"] != "Ready" {
+			continue
+		}
+
+		violation = violation.WithFieldPath(fmt.Sprintf("status.conditions[%d]", i))
+		for _, key := range []string{"reason", "message"} {
+			if value, ok := conditionAsMap[key].(string); ok && value != "" {
+				violation = violation.WithDetail(key, value)
+			}
+		}
+		break
+	}
+	return violation
+}
+
 func isResourceReady(resource *unstructured.Unstructured) (bool, error) {
 	conditions, conditionsFound, err := unstructured.NestedSlice(resource.Object, "status", "conditions")
 	if err != nil {
@@ -11,7 +11,10 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/SAP/k8s-resource-validator/pkg/common"
 	"github.com/SAP/k8s-resource-validator/pkg/test_utils"
@@ -95,12 +98,16 @@ var _ = Describe("Readiness", func() {
 			readyCondition := make(map[string]interface{})
 			readyCondition["type"] = "Ready"
 			readyCondition["status"] = "False"
-			readyConditions := []interface{}{readyCondition}
+			readyCondition["reason"] = "ContainersNotReady"
+			readyConditions := []interface{}{map[string]interface{}{"type": "Initialized", "status": "True"}, readyCondition}
 			unstructured.SetNestedField(readinessUnstructuredResource.Object, readyConditions, "status", "conditions")
 
 			violationsArray, err := readinessValidator.Validate([]unstructured.Unstructured{readinessUnstructuredResource})
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleResourceNotReady))
+			Expect(violationsArray[0].FieldPath).To(Equal("status.conditions[1]"))
+			Expect(violationsArray[0].Details).To(Equal(map[string]string{"reason": "ContainersNotReady"}))
 		})
 
 		It("pod is not ready (missing status)", func() {
@@ -110,6 +117,7 @@ var _ = Describe("Readiness", func() {
 			violationsArray, err := freshnessValidator.Validate([]unstructured.Unstructured{readinessUnstructuredResource})
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].FieldPath).To(Equal("status.ready"))
 		})
 
 		It("could not read readiness file", func() {
@@ -145,6 +153,7 @@ var _ = Describe("Readiness", func() {
 			violationsArray, err := readinessValidator.Validate([]unstructured.Unstructured{readinessUnstructuredResource})
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(1))
+			Expect(violationsArray[0].RuleID).To(Equal(ruleResourceNotFound))
 		})
 
 		It("resource in readiness list not found, but ignoring", func() {
@@ -160,5 +169,39 @@ var _ = Describe("Readiness", func() {
 			Expect(err).To(Succeed())
 			Expect(violationsArray).To(HaveLen(0))
 		})
+
+		It("readinesslist files and ConfigMaps", func() {
+			_ = afero.WriteFile(appFs, filepath.Join(configDirectory, "readinesslist.d", "team-b.yaml"), []byte("- {name: b, namespace: team-b, kind: Pod}"), 0644)
+			readinesslistConfigMap := func(namespace string, readinesslist string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "readinesslist", Labels: map[string]string{"readinesslist": "true"}},
+					Data:       map[string]string{readinesslistFile: readinesslist},
+				}
+			}
+			clientSet := k8sfake.NewSimpleClientset(
+				readinesslistConfigMap("team-a", "- {name: a, namespace: team-a, kind: Pod}\n- {name: c, namespace: team-c, kind: Pod}"),
+				readinesslistConfigMap("team-d", "- {namespace: team-d}"),
+			)
+			readinessValidator, err := NewReadinessValidator(ctx, configDirectory, false)
+			Expect(err).To(Succeed())
+			config := common.NewConfig()
+			config.Readiness.ConfigMaps = common.ListConfigMapsConfig{Selector: "readinesslist=true"}
+			readinessValidator.(common.ConfigAware).SetConfig(config)
+			readinessValidator.(common.ClientAware).SetClientSet(clientSet)
+
+			violationsArray, err := readinessValidator.Validate([]unstructured.Unstructured{readinessUnstructuredResource})
+			Expect(err).To(Succeed())
+			var rules []string
+			for _, violation := range violationsArray {
+				rules = append(rules, violation.RuleID+" "+common.NewResourceKey(violation.Resource).String())
+			}
+			Expect(rules).To(ConsistOf(
+				common.RuleNamespaceNotOwned+" ConfigMap/team-a/readinesslist",
+				ruleInvalidConfigMap+" ConfigMap/team-d/readinesslist",
+				ruleResourceNotFound+" Pod/team-b/b",
+				ruleResourceNotFound+" Pod/team-a/a",
+				ruleResourceNotReady+" Pod/namespace/name", // of readinesslist.yaml, without status
+			))
+		})
 	})
 })